@@ -4,41 +4,145 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"reflect"
 	"slices"
 	"strconv"
 	"strings"
-
-	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var errBadJsonDoc = fmt.Errorf("Invalid Json Document")
 
+// Path is a JSONPath such as "$.items[*].name", where "[*]" matches every
+// element of an array and ".*" every member of an object.
 type Path string
 type Key string
 type EntitySets map[Path]Key
 
 type Collections struct {
-	EntitySets EntitySets
-	Arrays     []Path
-	Atomics    []Path
+	EntitySets EntitySets `json:"entitySets,omitempty" yaml:"entitySets,omitempty"`
+	// KeyedArrays are arrays whose items are matched by key like EntitySets,
+	// but whose order is significant like Arrays.
+	KeyedArrays EntitySets `json:"keyedArrays,omitempty" yaml:"keyedArrays,omitempty"`
+	Arrays      []Path     `json:"arrays,omitempty" yaml:"arrays,omitempty"`
+	// Sets are lists that are known to be merged as sets where a strategic
+	// merge patch is applied, such as "x-kubernetes-list-type: set" lists.
+	// Lists are compared as sets by default, but strategic merge patches only
+	// merge the ones declared here and replace the others as a whole.
+	Sets    []Path `json:"sets,omitempty" yaml:"sets,omitempty"`
+	Atomics []Path `json:"atomics,omitempty" yaml:"atomics,omitempty"`
+	// UnorderedAtomics are atomics whose arrays, at any depth, are compared
+	// ignoring order. They are replaced wholesale like Atomics.
+	UnorderedAtomics []Path       `json:"unorderedAtomics,omitempty" yaml:"unorderedAtomics,omitempty"`
+	NullHandling     NullHandling `json:"nullHandling,omitempty" yaml:"nullHandling,omitempty"`
+	// EmptyEquivalence treats an absent member, null, [] and {} as equal
+	// everywhere. EmptyEquivalentPaths does the same for specific members only.
+	EmptyEquivalence     bool   `json:"emptyEquivalence,omitempty" yaml:"emptyEquivalence,omitempty"`
+	EmptyEquivalentPaths []Path `json:"emptyEquivalentPaths,omitempty" yaml:"emptyEquivalentPaths,omitempty"`
+	// MutableKeys are EntitySets whose item keys may change. In ExactMatch
+	// mode, items left unmatched by key are paired by similarity and updated
+	// in place, key included, instead of being removed and added again.
+	MutableKeys []Path `json:"mutableKeys,omitempty" yaml:"mutableKeys,omitempty"`
+	// FuzzySets are sets whose changed elements are paired by similarity in
+	// ExactMatch mode and diffed recursively, instead of being removed and
+	// added again as a whole.
+	FuzzySets []Path `json:"fuzzySets,omitempty" yaml:"fuzzySets,omitempty"`
+	// SimilarityThreshold is the minimum similarity, between 0 and 1, for two
+	// items to be paired. It defaults to 0.5.
+	SimilarityThreshold float64 `json:"similarityThreshold,omitempty" yaml:"similarityThreshold,omitempty"`
+	// Defaults holds the value a member takes when it is omitted, by JSONPath.
+	// A member missing on one side compares equal to its default on the other.
+	Defaults map[Path]any `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+}
+
+// NullHandling controls how null values on object members are interpreted
+// while diffing. Nulls inside arrays are always treated as literal values.
+type NullHandling string
+
+const (
+	// NullAsValue treats null as a literal value. This is the default.
+	NullAsValue NullHandling = "value"
+	// NullAsDelete treats a null in the modified document as a request to
+	// remove the member from the original document.
+	NullAsDelete NullHandling = "delete"
+	// NullAsAbsent treats a null member as if it was not present at all, on
+	// either side of the diff.
+	NullAsAbsent NullHandling = "absent"
+)
+
+// desiredValue returns the value to write into the original document for v.
+// When nulls mean delete, null members of v are dropped rather than written.
+func (c *Collections) desiredValue(v any) any {
+	if c.NullHandling == NullAsDelete {
+		return removeNullFields(v)
+	}
+	return v
+}
+
+func (c *Collections) isEmptyEquivalent(path string) bool {
+	if c.EmptyEquivalence {
+		return true
+	}
+	return containsPath(c.EmptyEquivalentPaths, toJsonPath(path))
+}
+
+// isEmpty reports whether v is null, an empty array or an empty object.
+func isEmpty(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+// isDefault reports whether v is the declared default value for path.
+func (c *Collections) isDefault(path string, v any) bool {
+	defaultValue, ok := lookupPath(c.Defaults, toJsonPath(path))
+	if !ok {
+		return false
+	}
+	return matchesValue(toJsonValue(defaultValue), v, !c.isArray(path))
+}
+
+// toJsonValue converts v to the types produced by json.Unmarshal, so Go
+// values such as ints can be compared with decoded documents.
+func toJsonValue(v any) any {
+	result, err := decodeValue(v)
+	if err != nil {
+		return v
+	}
+	return result
 }
 
 func (c *Collections) isArray(path string) bool {
-	jsonPath := toJsonPath(path)
-	return slices.Contains(c.Arrays, Path(jsonPath))
+	return containsPath(c.Arrays, toJsonPath(path))
+}
+
+func (c *Collections) isSet(path string) bool {
+	return containsPath(c.Sets, toJsonPath(path))
 }
 
 func (c *Collections) isEntitySet(path string) bool {
-	jsonPath := toJsonPath(path)
-	_, ok := c.EntitySets[Path(jsonPath)]
+	_, ok := c.EntitySets.Get(Path(toJsonPath(path)))
+	return ok
+}
+
+func (c *Collections) isKeyedArray(path string) bool {
+	_, ok := c.KeyedArrays.Get(Path(toJsonPath(path)))
 	return ok
 }
 
 func (c *Collections) isAtomic(path string) bool {
 	jsonPath := toJsonPath(path)
-	return slices.Contains(c.Atomics, Path(jsonPath))
+	return containsPath(c.Atomics, jsonPath) || containsPath(c.UnorderedAtomics, jsonPath)
+}
+
+func (c *Collections) isUnorderedAtomic(path string) bool {
+	return containsPath(c.UnorderedAtomics, toJsonPath(path))
 }
 
 func (s EntitySets) Add(path Path, key Key) {
@@ -48,12 +152,75 @@ func (s EntitySets) Add(path Path, key Key) {
 	s[path] = key
 }
 
+// Get returns the key of the EntitySet at path. EntitySets declared with a
+// ".*" wildcard member apply to the matching paths.
 func (s EntitySets) Get(path Path) (Key, bool) {
 	if s == nil {
 		return "", false
 	}
-	key, ok := s[path]
-	return key, ok
+	return lookupPath(s, string(path))
+}
+
+// containsPath reports whether one of paths matches the JSONPath jsonPath.
+func containsPath(paths []Path, jsonPath string) bool {
+	return slices.ContainsFunc(paths, func(path Path) bool { return matchesJsonPath(path, jsonPath) })
+}
+
+// lookupPath returns the value held by the first path of m that matches the
+// JSONPath jsonPath, trying an exact match first.
+func lookupPath[V any](m map[Path]V, jsonPath string) (V, bool) {
+	if v, ok := m[Path(jsonPath)]; ok {
+		return v, true
+	}
+	for _, path := range slices.Sorted(maps.Keys(m)) {
+		if strings.Contains(string(path), ".*") && matchesJsonPath(path, jsonPath) {
+			return m[path], true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// matchesJsonPath reports whether the JSONPath jsonPath, as generated by
+// toJsonPath, matches path. A ".*" member of path matches any member, so that
+// the values of maps can be addressed. It matches "[*]" too, which is what
+// toJsonPath makes of numeric members such as "/volumes/0".
+func matchesJsonPath(path Path, jsonPath string) bool {
+	if string(path) == jsonPath {
+		return true
+	}
+	if !strings.Contains(string(path), ".*") {
+		return false
+	}
+	pattern, segments := jsonPathSegments(string(path)), jsonPathSegments(jsonPath)
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, segment := range segments {
+		if pattern[i] != segment && (pattern[i] != ".*" || !strings.HasPrefix(segment, ".") && segment != "[*]") {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPathSegments splits a JSONPath into its members, such as ".name", ".*"
+// and "[*]", leaving out the leading "$".
+func jsonPathSegments(jsonPath string) []string {
+	segments := []string{}
+	rest := strings.TrimPrefix(jsonPath, "$")
+	for rest != "" {
+		end := strings.IndexAny(rest[1:], ".[") + 1
+		if strings.HasPrefix(rest, "[") {
+			end = strings.Index(rest, "]") + 1
+		}
+		if end <= 0 {
+			end = len(rest)
+		}
+		segments = append(segments, rest[:end])
+		rest = rest[end:]
+	}
+	return segments
 }
 
 func toJsonPath(path string) string {
@@ -91,6 +258,7 @@ const (
 type JsonPatchOperation struct {
 	Operation string `json:"op"`
 	Path      string `json:"path"`
+	From      string `json:"from,omitempty"`
 	Value     any    `json:"value,omitempty"`
 }
 
@@ -104,6 +272,9 @@ func (j *JsonPatchOperation) MarshalJson() ([]byte, error) {
 	b.WriteString("{")
 	b.WriteString(fmt.Sprintf(`"op":"%s"`, j.Operation))
 	b.WriteString(fmt.Sprintf(`,"path":"%s"`, j.Path))
+	if j.Operation == "move" || j.Operation == "copy" {
+		b.WriteString(fmt.Sprintf(`,"from":"%s"`, j.From))
+	}
 	// Consider omitting Value for non-nullable operations.
 	if j.Value != nil || j.Operation == "replace" || j.Operation == "add" || j.Operation == "test" {
 		v, err := json.Marshal(j.Value)
@@ -127,35 +298,88 @@ func NewPatch(operation, path string, value any) JsonPatchOperation {
 	return JsonPatchOperation{Operation: operation, Path: path, Value: value}
 }
 
+func NewMovePatch(from, path string) JsonPatchOperation {
+	return JsonPatchOperation{Operation: "move", Path: path, From: from}
+}
+
 // CreatePatch creates a patch as specified in http://jsonpatch.com/
 //
 // 'a' is original, 'b' is the modified document. Both are to be given as json encoded content.
 // The function will return an array of JsonPatchOperations
 // If ignoreArrayOrder is true, arrays with the same elements but in different order will be considered equal
 //
-// An e rror will be returned if any of the two documents are invalid.
-func CreatePatch(a, b []byte, collections Collections, ignoredFields []Path, strategy PatchStrategy) ([]JsonPatchOperation, error) {
+// An e rror will be returned if any of the two documents are invalid, or if the
+// patch exceeds the limits set in options.
+func CreatePatch(a, b []byte, collections Collections, ignoredFields []Path, strategy PatchStrategy, options ...PatchOption) ([]JsonPatchOperation, error) {
+	aUnmarshalled, bUnmarshalled, err := unmarshalDocuments(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return createPatch(aUnmarshalled, bUnmarshalled, collections, ignoredFields, strategy, options)
+}
+
+// createPatch creates the patch between the decoded documents aUnmarshalled
+// and bUnmarshalled.
+func createPatch(aUnmarshalled, bUnmarshalled any, collections Collections, ignoredFields []Path, strategy PatchStrategy, options []PatchOption) ([]JsonPatchOperation, error) {
+	aWithoutIgnoredFields, bWithoutIgnoredFields, err := prepareValues(aUnmarshalled, bUnmarshalled, collections, ignoredFields)
+	if err != nil {
+		return nil, err
+	}
+	opts := newPatchOptions(options)
+	patch, err := handleValues(aWithoutIgnoredFields, bWithoutIgnoredFields, "", []JsonPatchOperation{}, strategy, collections, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Compaction works on the original document, so that the values it
+	// replaces keep their ignored fields.
+	patch = compactPatch(aUnmarshalled, patch, opts.compactionRatio, collections)
+	return enforceLimits(aUnmarshalled, patch, opts)
+}
+
+// prepareDocuments unmarshals the original and modified documents, and returns
+// the original as is along with both documents as they are to be compared.
+func prepareDocuments(a, b []byte, collections Collections, ignoredFields []Path) (any, any, any, error) {
+	aUnmarshalled, bUnmarshalled, err := unmarshalDocuments(a, b)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aWithoutIgnoredFields, bWithoutIgnoredFields, err := prepareValues(aUnmarshalled, bUnmarshalled, collections, ignoredFields)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return aUnmarshalled, aWithoutIgnoredFields, bWithoutIgnoredFields, nil
+}
+
+func unmarshalDocuments(a, b []byte) (any, any, error) {
 	var aUnmarshalled any
 	var bUnmarshalled any
 
 	err := json.Unmarshal(a, &aUnmarshalled)
 	if err != nil {
-		return nil, errBadJsonDoc
+		return nil, nil, errBadJsonDoc
 	}
 	err = json.Unmarshal(b, &bUnmarshalled)
 	if err != nil {
-		return nil, errBadJsonDoc
+		return nil, nil, errBadJsonDoc
 	}
+	return aUnmarshalled, bUnmarshalled, nil
+}
+
+// prepareValues returns the decoded documents as they are to be compared.
+func prepareValues(aUnmarshalled, bUnmarshalled any, collections Collections, ignoredFields []Path) (any, any, error) {
 	aWithoutIgnoredFields, err := removeIgnoredFields(aUnmarshalled, ignoredFields)
 	if err != nil {
-		return nil, fmt.Errorf("error removing ignored fields from original document: %w", err)
+		return nil, nil, fmt.Errorf("error removing ignored fields from original document: %w", err)
 	}
 	bWithoutIgnoredFields, err := removeIgnoredFields(bUnmarshalled, ignoredFields)
 	if err != nil {
-		return nil, fmt.Errorf("error removing ignored fields from modified document: %w", err)
+		return nil, nil, fmt.Errorf("error removing ignored fields from modified document: %w", err)
 	}
-
-	return handleValues(aWithoutIgnoredFields, bWithoutIgnoredFields, "", []JsonPatchOperation{}, strategy, collections)
+	if collections.NullHandling == NullAsAbsent {
+		aWithoutIgnoredFields = removeNullFields(aWithoutIgnoredFields)
+		bWithoutIgnoredFields = removeNullFields(bWithoutIgnoredFields)
+	}
+	return aWithoutIgnoredFields, bWithoutIgnoredFields, nil
 }
 
 // Returns true if the values matches (must be json types)
@@ -167,6 +391,8 @@ func matchesValue(av, bv any, ignoreArrayOrder bool) bool {
 		return false
 	}
 	switch at := av.(type) {
+	case nil:
+		return true
 	case string:
 		bt := bv.(string)
 		if bt == at {
@@ -184,13 +410,14 @@ func matchesValue(av, bv any, ignoreArrayOrder bool) bool {
 		}
 	case map[string]any:
 		bt := bv.(map[string]any)
-		for key := range at {
-			if !matchesValue(at[key], bt[key], ignoreArrayOrder) {
-				return false
-			}
+		// A member must be present on both sides: an absent member and a null
+		// one are different values.
+		if len(bt) != len(at) {
+			return false
 		}
-		for key := range bt {
-			if !matchesValue(at[key], bt[key], ignoreArrayOrder) {
+		for key := range at {
+			bValue, ok := bt[key]
+			if !ok || !matchesValue(at[key], bValue, ignoreArrayOrder) {
 				return false
 			}
 		}
@@ -250,10 +477,9 @@ func matchesValue(av, bv any, ignoreArrayOrder bool) bool {
 // character sequence.  This is performed by first transforming any
 // occurrence of the sequence '~1' to '/', and then transforming any
 // occurrence of the sequence '~0' to '~'.
-//   TODO decode support:
-//   var rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
 
 var rfc6901Encoder = strings.NewReplacer("~", "~0", "/", "~1")
+var rfc6901Decoder = strings.NewReplacer("~1", "/", "~0", "~")
 
 func makePath(path string, newPart any) string {
 	key := rfc6901Encoder.Replace(fmt.Sprintf("%v", newPart))
@@ -267,24 +493,40 @@ func makePath(path string, newPart any) string {
 }
 
 // diff returns the (recursive) difference between a and b as an array of JsonPatchOperations.
-func diff(a, b map[string]any, path string, patch []JsonPatchOperation, strategy PatchStrategy, collections Collections) ([]JsonPatchOperation, error) {
+func diff(a, b map[string]any, path string, patch []JsonPatchOperation, strategy PatchStrategy, collections Collections, options patchOptions) ([]JsonPatchOperation, error) {
 	//TODO: handle EnsureAbsent strategy
-	for key, bv := range b {
+	// Keys are visited in sorted order so the generated patch is deterministic.
+	for _, key := range slices.Sorted(maps.Keys(b)) {
+		bv := b[key]
 		p := makePath(path, key)
 		av, ok := a[key]
-		// If the key is not present in a, add it
+		// Absent, null, [] and {} are all the same where they are equivalent
+		if isEmpty(bv) && (!ok || isEmpty(av)) && collections.isEmptyEquivalent(p) {
+			continue
+		}
+		// A null member asks for the member to be removed, if there is one
+		if bv == nil && collections.NullHandling == NullAsDelete {
+			if ok && av != nil {
+				patch = append(patch, NewPatch("remove", p, nil))
+			}
+			continue
+		}
+		// If the key is not present in a, add it unless it has its default value
 		if !ok {
-			patch = append(patch, NewPatch("add", p, bv))
+			if collections.isDefault(p, bv) {
+				continue
+			}
+			patch = append(patch, NewPatch("add", p, collections.desiredValue(bv)))
 			continue
 		}
 		// If types have changed, replace completely
 		if reflect.TypeOf(av) != reflect.TypeOf(bv) {
-			patch = append(patch, NewPatch("replace", p, bv))
+			patch = append(patch, NewPatch("replace", p, collections.desiredValue(bv)))
 			continue
 		}
 		// Types are the same, compare values
 		var err error
-		patch, err = handleValues(av, bv, p, patch, strategy, collections)
+		patch, err = handleValues(av, bv, p, patch, strategy, collections, options)
 		if err != nil {
 			return nil, err
 		}
@@ -296,14 +538,18 @@ func diff(a, b map[string]any, path string, patch []JsonPatchOperation, strategy
 	// but not declared on desired (e.g. an out-of-band Tags entry on a
 	// resource whose IaC declares no tags). Scoped to EntitySet specifically
 	// — Arrays and other types preserve the historical "never remove keys
-	// from objects" contract that callers rely on (see TestComplexVsEmpty).
+	// from objects" contract that callers rely on (see TestComplexVsEmpty),
+	// except within items paired by similarity, which have to become equal.
 	if strategy == PatchStrategyExactMatch {
-		for key := range a {
+		for _, key := range slices.Sorted(maps.Keys(a)) {
 			if _, found := b[key]; found {
 				continue
 			}
 			p := makePath(path, key)
-			if collections.isEntitySet(p) {
+			if isEmpty(a[key]) && collections.isEmptyEquivalent(p) || collections.isDefault(p, a[key]) {
+				continue
+			}
+			if collections.isEntitySet(p) || options.exactMembers {
 				patch = append(patch, NewPatch("remove", p, nil))
 			}
 		}
@@ -311,19 +557,26 @@ func diff(a, b map[string]any, path string, patch []JsonPatchOperation, strategy
 	return patch, nil
 }
 
-func handleValues(av, bv any, p string, patch []JsonPatchOperation, strategy PatchStrategy, collections Collections) ([]JsonPatchOperation, error) {
+func handleValues(av, bv any, p string, patch []JsonPatchOperation, strategy PatchStrategy, collections Collections, options patchOptions) ([]JsonPatchOperation, error) {
 	var err error
 	ignoreArrayOrder := !collections.isArray(p)
+	// The location exists in the original document, so a change of type is
+	// always a replace, never an add. This also covers null at the root.
+	if reflect.TypeOf(av) != reflect.TypeOf(bv) {
+		patch = append(patch, NewPatch("replace", p, collections.desiredValue(bv)))
+		return patch, nil
+	}
+	// Atomic values of any type are replaced as a whole when they differ.
+	if collections.isAtomic(p) {
+		if !matchesValue(av, bv, collections.isUnorderedAtomic(p)) {
+			patch = append(patch, NewPatch("replace", p, collections.desiredValue(bv)))
+		}
+		return patch, nil
+	}
 	switch at := av.(type) {
 	case map[string]any:
-		if collections.isAtomic(p) {
-			if !matchesValue(av, bv, false) {
-				patch = append(patch, NewPatch("replace", p, bv))
-			}
-			return patch, nil
-		}
 		bt := bv.(map[string]any)
-		patch, err = diff(at, bt, p, patch, strategy, collections)
+		patch, err = diff(at, bt, p, patch, strategy, collections, options)
 		if err != nil {
 			return nil, err
 		}
@@ -334,17 +587,18 @@ func handleValues(av, bv any, p string, patch []JsonPatchOperation, strategy Pat
 		}
 		return patch, nil
 	case []any:
-		bt, replaceWithOtherCollection := bv.([]any)
+		bt := bv.([]any)
 		switch {
-		case !replaceWithOtherCollection:
-			// If the types are different, we replace the whole array
-			patch = append(patch, NewPatch("replace", p, bv))
+		case collections.isKeyedArray(p):
+			if !matchesValue(at, bt, false) {
+				patch = append(patch, compareArray(at, bt, p, strategy, collections, options)...)
+			}
 		case collections.isArray(p) && len(at) != len(bt):
-			patch = append(patch, compareArray(at, bt, p, strategy, collections)...)
+			patch = append(patch, compareArray(at, bt, p, strategy, collections, options)...)
 		case collections.isArray(p) && len(at) == len(bt):
 			// If arrays have the same length, we can compare them element by element
 			for i := range bt {
-				patch, err = handleValues(at[i], bt[i], makePath(p, i), patch, strategy, collections)
+				patch, err = handleValues(at[i], bt[i], makePath(p, i), patch, strategy, collections, options)
 				if err != nil {
 					return nil, err
 				}
@@ -352,16 +606,11 @@ func handleValues(av, bv any, p string, patch []JsonPatchOperation, strategy Pat
 		default:
 			// If this is not an array, we treat it as a set of values.
 			if !matchesValue(at, bt, true) {
-				patch = append(patch, compareArray(at, bt, p, strategy, collections)...)
+				patch = append(patch, compareArray(at, bt, p, strategy, collections, options)...)
 			}
 		}
 	case nil:
-		switch bv.(type) {
-		case nil:
 		// Both nil, fine.
-		default:
-			patch = append(patch, NewPatch("add", p, bv))
-		}
 	default:
 		panic(fmt.Sprintf("Unknown type:%T ", av))
 	}
@@ -369,72 +618,117 @@ func handleValues(av, bv any, p string, patch []JsonPatchOperation, strategy Pat
 }
 
 // compareArray generates remove and add operations for `av` and `bv`.
-func compareArray(av, bv []any, p string, strategy PatchStrategy, collections Collections) []JsonPatchOperation {
+func compareArray(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) []JsonPatchOperation {
 	retval := []JsonPatchOperation{}
 
 	switch {
 	case collections.isArray(p):
-		if strategy == PatchStrategyExactMatch {
-			// Find elements that need to be removed
-			processArray(av, bv, func(i int, value any) {
-				retval = append(retval, NewPatch("remove", makePath(p, i), nil))
-			}, strategy)
-			reversed := make([]JsonPatchOperation, len(retval))
-			for i := range retval {
-				reversed[len(retval)-1-i] = retval[i]
-			}
-			retval = reversed
-		}
-
-		// Find elements that need to be added.
-		// NOTE we pass in `bv` then `av` so that processArray can find the missing elements.
-		processArray(bv, av, func(i int, value any) {
-			retval = append(retval, NewPatch("add", makePath(p, i), value))
-		}, strategy)
+		retval = append(retval, compareSequence(av, bv, p, strategy, collections, options)...)
+	case collections.isKeyedArray(p):
+		retval = append(retval, compareKeyedArray(av, bv, p, strategy, collections, options)...)
 	case collections.isEntitySet(p):
 		if len(av) == len(bv) && matchesValue(av, bv, true) {
 			return retval
 		}
-		// TODO: removing is not tested yest!
-		removals := 0
-		if strategy == PatchStrategyExactMatch {
-			// Find elements that need to be removed
-			elementsBeforeRemove := len(retval)
-			processIdentitySet(av, bv, p, func(i, o int, value any) {
+		key, _ := collections.EntitySets.Get(Path(toJsonPath(p)))
+		matches := processIdentitySet(av, bv, key)
+		if strategy == PatchStrategyExactMatch && collections.isMutableKey(p) {
+			pairRenamedEntities(av, bv, key, matches, collections.similarityThreshold())
+		}
+		remove := func(i int) bool {
+			return matches[i] < 0 && strategy == PatchStrategyExactMatch
+		}
+		// Remove from the end so the indexes of the remaining items stay valid.
+		for i := len(av) - 1; i >= 0; i-- {
+			if remove(i) {
 				retval = append(retval, NewPatch("remove", makePath(p, i), nil))
-			}, func(ops []JsonPatchOperation) { // no-op
-			}, strategy, collections)
-			removals = len(retval) - elementsBeforeRemove
-			reversed := make([]JsonPatchOperation, len(retval))
-			for i := range retval {
-				reversed[len(retval)-1-i] = retval[i]
 			}
-			retval = reversed
 		}
-		offset := len(av) - removals
-		processIdentitySet(bv, av, p, func(i, o int, value any) {
-			retval = append(retval, NewPatch("add", makePath(p, o+offset), value))
-		}, func(ops []JsonPatchOperation) {
-			retval = append(retval, ops...)
-		}, strategy, collections)
+		// Update matched items at their position once the removes are applied.
+		// Renamed items are matched by similarity rather than by key, and
+		// lose the members they have no longer.
+		renamed := options
+		renamed.exactMembers = true
+		index := 0
+		found := make(map[int]struct{}, len(bv))
+		for i := range av {
+			if remove(i) {
+				continue
+			}
+			if j := matches[i]; j >= 0 {
+				found[j] = struct{}{}
+				itemOptions := options
+				if entityIdentity(av[i], key) != entityIdentity(bv[j], key) {
+					itemOptions = renamed
+				}
+				updateOps, err := handleValues(av[i], bv[j], makePath(p, index), []JsonPatchOperation{}, strategy, collections, itemOptions)
+				if err == nil {
+					retval = append(retval, updateOps...)
+				}
+			}
+			index++
+		}
+		// Append the items that are new.
+		for j, v := range bv {
+			if _, ok := found[j]; !ok {
+				retval = append(retval, NewPatch("add", options.appendPath(p, index), collections.desiredValue(v)))
+				index++
+			}
+		}
 	default: // default to set
 		if len(av) == len(bv) && matchesValue(av, bv, true) {
 			return retval
 		}
 		// TODO: removing is not tested yest!
 		// also we need to check for PatchStrategyEnsureAbsent
-		removals := 0
+		removed := []int{}
+		isRemoved := make(map[int]struct{})
 		if strategy == PatchStrategyExactMatch {
 			// Find elements that need to be removed
-			elementsBeforeRemove := len(retval)
-			processSet(av, bv, func(i int, value any) { retval = append(retval, NewPatch("remove", makePath(p, i), nil)) })
-			removals = len(retval) - elementsBeforeRemove
-			reversed := make([]JsonPatchOperation, len(retval))
-			for i := range retval {
-				reversed[len(retval)-1-i] = retval[i]
+			processSet(av, bv, func(i int, _ any) {
+				removed = append(removed, i)
+				isRemoved[i] = struct{}{}
+			})
+		}
+		added := []int{}
+		processSet(bv, av, func(j int, _ any) { added = append(added, j) })
+
+		// Changed elements of fuzzy sets are updated in place rather than removed and added.
+		paired := make(map[int]int)
+		pairedTargets := make(map[int]struct{})
+		if strategy == PatchStrategyExactMatch && collections.isFuzzySet(p) {
+			score := func(i, j int) float64 { return similarity(av[i], bv[j]) }
+			for _, pair := range pairBySimilarity(removed, added, score, collections.similarityThreshold()) {
+				paired[pair[0]] = pair[1]
+				pairedTargets[pair[1]] = struct{}{}
 			}
-			retval = reversed
 		}
+
+		removals := 0
+		for k := len(removed) - 1; k >= 0; k-- {
+			if _, ok := paired[removed[k]]; !ok {
+				retval = append(retval, NewPatch("remove", makePath(p, removed[k]), nil))
+				removals++
+			}
+		}
+		// Paired elements have to become equal to their pair, so they also lose
+		// the members they have no longer.
+		pairedOptions := options
+		pairedOptions.exactMembers = true
+		index := 0
+		for i := range av {
+			if j, ok := paired[i]; ok {
+				// index is the position of the element once the removes are applied.
+				updateOps, err := handleValues(av[i], bv[j], makePath(p, index), []JsonPatchOperation{}, strategy, collections, pairedOptions)
+				if err == nil {
+					retval = append(retval, updateOps...)
+				}
+			} else if _, ok := isRemoved[i]; ok {
+				continue
+			}
+			index++
+		}
+
 		offset := len(av) - removals
 		// Use a counter for add operations instead of the target array index.
 		// When some target elements are retained (exist in both source and target),
@@ -442,10 +736,13 @@ func compareArray(av, bv []any, p string, strategy PatchStrategy, collections Co
 		// This causes incorrect indices when there's overlap between source and target.
 		// The counter tracks how many elements have actually been added.
 		addIndex := 0
-		processSet(bv, av, func(_ int, value any) {
-			retval = append(retval, NewPatch("add", makePath(p, addIndex+offset), value))
+		for _, j := range added {
+			if _, ok := pairedTargets[j]; ok {
+				continue
+			}
+			retval = append(retval, NewPatch("add", options.appendPath(p, addIndex+offset), collections.desiredValue(bv[j])))
 			addIndex++
-		})
+		}
 	}
 
 	return retval
@@ -487,178 +784,442 @@ func processSet(av, bv []any, applyOp func(i int, value any)) {
 	}
 }
 
-func processIdentitySet(av, bv []any, path string, applyOp func(i, o int, value any), replaceOps func(ops []JsonPatchOperation), strategy PatchStrategy, collections Collections) {
-	foundIndexes := make(map[int]struct{}, len(av))
-	lookup := make(map[string]int)
+// compareKeyedArray generates the operations that turn `av` into `bv` for an
+// array whose items are matched by key and whose order is significant.
+// Unmatched items of `av` are removed in ExactMatch mode and stay where they
+// are otherwise. Items of `bv` are moved or inserted right after the item that
+// precedes them in `bv`, leaving the longest run of items that are already in
+// order in place. Matched items are then diffed at their final position.
+func compareKeyedArray(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) []JsonPatchOperation {
+	retval := []JsonPatchOperation{}
+	key, _ := collections.KeyedArrays.Get(Path(toJsonPath(p)))
+	matches := processIdentitySet(av, bv, key)
+
+	// current holds, for each position of the array being patched, the index
+	// of the item in `bv` it holds, or -1 for unmatched items of `av`.
+	current := []int{}
+	for i := len(av) - 1; i >= 0; i-- {
+		if matches[i] < 0 && strategy == PatchStrategyExactMatch {
+			retval = append(retval, NewPatch("remove", makePath(p, i), nil))
+		}
+	}
+	for i := range av {
+		if matches[i] >= 0 || strategy != PatchStrategyExactMatch {
+			current = append(current, matches[i])
+		}
+	}
 
-	for i, v := range bv {
-		key, ok := collections.EntitySets.Get(Path(toJsonPath(path)))
-		if !ok {
-			continue // If we don't have a key for this path, skip
+	inOrder := longestIncreasingRun(current)
+	position := func(j int) int { return slices.Index(current, j) }
+	for j, v := range bv {
+		target := 0
+		if j > 0 {
+			target = position(j-1) + 1
 		}
-		jsonBytes, err := json.Marshal(v.(map[string]any)[string(key)])
-		if err != nil {
-			continue // Skip if we can't marshal
+		from := position(j)
+		switch {
+		case from < 0:
+			retval = append(retval, NewPatch("add", makePath(p, target), collections.desiredValue(v)))
+			current = slices.Insert(current, target, j)
+		case !inOrder[j]:
+			if from < target {
+				target--
+			}
+			if from != target {
+				retval = append(retval, NewMovePatch(makePath(p, from), makePath(p, target)))
+				current = slices.Insert(slices.Delete(current, from, from+1), target, j)
+			}
 		}
-		jsonStr := string(jsonBytes)
-		lookup[jsonStr] = i
 	}
 
-	for i, v := range av {
-		key, ok := collections.EntitySets.Get(Path(toJsonPath(path)))
-		if !ok {
-			continue // If we don't have a key for this path, skip
+	for index, j := range current {
+		i := slices.Index(matches, j)
+		if j < 0 || i < 0 {
+			continue // unmatched or added
 		}
-		jsonBytes, err := json.Marshal(v.(map[string]any)[string(key)])
-		if err != nil {
-			applyOp(i, 0, v) // If we can't marshal, treat it as not found
-			continue
+		updateOps, err := handleValues(av[i], bv[j], makePath(p, index), []JsonPatchOperation{}, strategy, collections, options)
+		if err == nil {
+			retval = append(retval, updateOps...)
 		}
+	}
+	return retval
+}
 
-		jsonStr := string(jsonBytes)
-		if index, ok := lookup[jsonStr]; ok {
-			foundIndexes[i] = struct{}{}
-			updateOps, err := handleValues(bv[index], v, fmt.Sprintf("%s/%d", path, lookup[jsonStr]), []JsonPatchOperation{}, strategy, collections)
-			if err != nil {
-				return
-			}
-			replaceOps(updateOps)
+// longestIncreasingRun returns the values of `indexes` that form its longest
+// strictly increasing subsequence. Negative values are skipped.
+func longestIncreasingRun(indexes []int) map[int]bool {
+	// tails[k] is the position of the smallest value ending a run of length k+1.
+	tails := []int{}
+	previous := make([]int, len(indexes))
+	for pos, value := range indexes {
+		if value < 0 {
+			continue
 		}
+		k, _ := slices.BinarySearchFunc(tails, value, func(tail, value int) int { return indexes[tail] - value })
+		if k > 0 {
+			previous[pos] = tails[k-1]
+		} else {
+			previous[pos] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, pos)
+		} else {
+			tails[k] = pos
+		}
+	}
+
+	run := make(map[int]bool, len(tails))
+	if len(tails) == 0 {
+		return run
+	}
+	for pos := tails[len(tails)-1]; pos >= 0; pos = previous[pos] {
+		run[indexes[pos]] = true
+	}
+	return run
+}
+
+// processIdentitySet matches the items of `av` and `bv` by their `key` member.
+// It returns, for each item of `av`, the index of the matching item in `bv` or
+// -1 if there is none. Every item of `bv` is matched at most once.
+func processIdentitySet(av, bv []any, key Key) []int {
+	lookup := make(map[string][]int, len(bv))
+	for j, v := range bv {
+		identity := entityIdentity(v, key)
+		lookup[identity] = append(lookup[identity], j)
 	}
 
-	offset := 0
+	matches := make([]int, len(av))
 	for i, v := range av {
-		if _, ok := foundIndexes[i]; !ok {
-			applyOp(i, offset, v)
-			offset++
+		matches[i] = -1
+		identity := entityIdentity(v, key)
+		if candidates := lookup[identity]; len(candidates) > 0 {
+			matches[i] = candidates[0]
+			lookup[identity] = candidates[1:]
 		}
 	}
+	return matches
 }
 
-// processArray processes `av` and `bv` calling `applyOp` whenever a value is absent.
-// It keeps track of which indexes have already had `applyOp` called for and automatically skips them so you can process duplicate objects correctly.
-func processArray(av, bv []any, applyOp func(i int, value any), strategy PatchStrategy) {
-	foundIndexes := make(map[int]struct{}, len(av))
+// pairRenamedEntities updates `matches` so that items of `av` and `bv` that did
+// not match by key are paired when they are similar enough apart from the key.
+func pairRenamedEntities(av, bv []any, key Key, matches []int, threshold float64) {
+	matched := make(map[int]bool, len(bv))
+	unmatched := []int{}
+	for i, j := range matches {
+		if j < 0 {
+			unmatched = append(unmatched, i)
+		} else {
+			matched[j] = true
+		}
+	}
+	added := []int{}
+	for j := range bv {
+		if !matched[j] {
+			added = append(added, j)
+		}
+	}
+	withoutKey := func(v any) any {
+		item, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		rest := maps.Clone(item)
+		delete(rest, string(key))
+		return rest
+	}
+	score := func(i, j int) float64 {
+		_, aIsItem := av[i].(map[string]any)
+		_, bIsItem := bv[j].(map[string]any)
+		if !aIsItem || !bIsItem {
+			return 0
+		}
+		return similarity(withoutKey(av[i]), withoutKey(bv[j]))
+	}
+	for _, pair := range pairBySimilarity(unmatched, added, score, threshold) {
+		matches[pair[0]] = pair[1]
+	}
+}
+
+// entityIdentity returns the value that identifies an EntitySet item. Items
+// that are not objects are identified by their whole value.
+func entityIdentity(v any, key Key) string {
+	item, ok := v.(map[string]any)
+	if !ok {
+		jsonBytes, _ := json.Marshal(v)
+		return "value:" + string(jsonBytes)
+	}
+	jsonBytes, _ := json.Marshal(item[string(key)])
+	return "key:" + string(jsonBytes)
+}
+
+// compareSequence generates the operations that turn the array `av` into `bv`
+// when order is significant. Elements that are equal in the longest common
+// subsequence of both arrays are kept. In between, elements are paired in
+// order by similarity and diffed recursively, and the remaining ones are
+// removed or added. Existing elements are only changed in ExactMatch mode:
+// otherwise the missing elements are appended, or nothing is done in
+// EnsureAbsent mode.
+func compareSequence(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) []JsonPatchOperation {
 	switch strategy {
 	case PatchStrategyExactMatch:
-		reverseFoundIndexes := make(map[int]struct{}, len(bv))
-		for i, v := range av {
-			for i2, v2 := range bv {
-				if _, ok := reverseFoundIndexes[i2]; ok {
-					continue
-				}
-				if reflect.DeepEqual(v, v2) {
-					foundIndexes[i] = struct{}{}
-					reverseFoundIndexes[i2] = struct{}{}
-					break
-				}
-			}
-			if _, ok := foundIndexes[i]; !ok {
-				applyOp(i, v)
+	case PatchStrategyEnsureAbsent:
+		return []JsonPatchOperation{}
+	default:
+		return appendMissingElements(av, bv, p, collections)
+	}
+	retval := []JsonPatchOperation{}
+	equal := func(i, j int) bool { return matchesValue(av[i], bv[j], false) }
+
+	// Common prefix and suffix are kept as they are and need no table.
+	prefix := 0
+	for prefix < len(av) && prefix < len(bv) && equal(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(av)-prefix && suffix < len(bv)-prefix && equal(len(av)-1-suffix, len(bv)-1-suffix) {
+		suffix++
+	}
+	n, m := len(av)-prefix-suffix, len(bv)-prefix-suffix
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// av[prefix+i:prefix+n] and bv[prefix+j:prefix+m].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(prefix+i, prefix+j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
 			}
 		}
-	case PatchStrategyEnsureExists:
-		offset := len(bv)
-		bvCounts := make(map[string]int)
-		bvSeen := make(map[string]int) // Track how many we've seen during processing
+	}
 
-		for _, v := range bv {
-			jsonBytes, err := json.Marshal(v)
-			if err != nil {
-				continue // Skip if we can't marshal
+	// index is the position in the array being patched.
+	index := prefix
+	var gapA, gapB []any
+	flush := func() {
+		// Pair as many elements of the gap as possible, in order, choosing the
+		// most similar ones. pairs[i][j] is the best score for gapA[i:] and gapB[j:].
+		pairs := make([][]float64, len(gapA)+1)
+		for i := range pairs {
+			pairs[i] = make([]float64, len(gapB)+1)
+		}
+		for i := len(gapA) - 1; i >= 0; i-- {
+			for j := len(gapB) - 1; j >= 0; j-- {
+				pairs[i][j] = max(pairs[i+1][j], pairs[i][j+1], pairs[i+1][j+1]+1+similarity(gapA[i], gapB[j]))
 			}
-			jsonStr := string(jsonBytes)
-			bvCounts[jsonStr]++
 		}
-
-		for i, v := range av {
-			jsonBytes, err := json.Marshal(v)
-			if err != nil {
-				applyOp(i+offset, v) // If we can't marshal, treat it as not found
-				continue
+		// Consecutive removes are emitted from the last one to the first.
+		removes := 0
+		flushRemoves := func() {
+			for k := removes - 1; k >= 0; k-- {
+				retval = append(retval, NewPatch("remove", makePath(p, index+k), nil))
 			}
-
-			jsonStr := string(jsonBytes)
-			if bvCounts[jsonStr] > bvSeen[jsonStr] {
-				foundIndexes[i] = struct{}{}
-				bvSeen[jsonStr]++
+			removes = 0
+		}
+		i, j := 0, 0
+		for i < len(gapA) || j < len(gapB) {
+			switch {
+			case i < len(gapA) && j < len(gapB) && pairs[i][j] == pairs[i+1][j+1]+1+similarity(gapA[i], gapB[j]):
+				flushRemoves()
+				updateOps, err := handleValues(gapA[i], gapB[j], makePath(p, index), []JsonPatchOperation{}, strategy, collections, options)
+				if err == nil {
+					retval = append(retval, updateOps...)
+				}
+				index++
+				i++
+				j++
+			case j == len(gapB) || i < len(gapA) && pairs[i][j] == pairs[i+1][j]:
+				removes++
+				i++
+			default:
+				flushRemoves()
+				retval = append(retval, NewPatch("add", makePath(p, index), collections.desiredValue(gapB[j])))
+				index++
+				j++
 			}
 		}
+		flushRemoves()
+		gapA, gapB = nil, nil
+	}
 
-		for i, v := range av {
-			if _, ok := foundIndexes[i]; !ok {
-				applyOp(i+offset, v)
-			}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && equal(prefix+i, prefix+j):
+			flush()
+			index++
+			i++
+			j++
+		case j == m || i < n && lcs[i+1][j] >= lcs[i][j+1]:
+			gapA = append(gapA, av[prefix+i])
+			i++
+		default:
+			gapB = append(gapB, bv[prefix+j])
+			j++
 		}
-		return
-	case PatchStrategyEnsureAbsent:
-		return
 	}
+	flush()
+
+	return retval
 }
 
-func removeIgnoredFields(data any, ignoredFields []Path) (any, error) {
-	jsonBytes, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+// appendMissingElements appends the elements of `bv` that `av` lacks, counting
+// duplicates, to the end of `av`. Existing elements are left as they are.
+func appendMissingElements(av, bv []any, p string, collections Collections) []JsonPatchOperation {
+	retval := []JsonPatchOperation{}
+	found := make([]bool, len(av))
+	index := len(av)
+BLoop:
+	for _, v := range bv {
+		for i, e := range av {
+			if !found[i] && matchesValue(e, v, false) {
+				found[i] = true
+				continue BLoop
+			}
+		}
+		retval = append(retval, NewPatch("add", makePath(p, index), collections.desiredValue(v)))
+		index++
 	}
+	return retval
+}
 
-	jsonStr := string(jsonBytes)
+// removeNullFields returns a copy of data without object members whose value
+// is null, at any depth. Null array elements are kept.
+func removeNullFields(data any) any {
+	switch t := data.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(t))
+		for key, value := range t {
+			if value == nil {
+				continue
+			}
+			result[key] = removeNullFields(value)
+		}
+		return result
+	case []any:
+		result := make([]any, len(t))
+		for i, value := range t {
+			result[i] = removeNullFields(value)
+		}
+		return result
+	default:
+		return data
+	}
+}
 
-	for _, path := range ignoredFields {
-		jsonStr, err = removeJSONPath(jsonStr, string(path))
+// removeIgnoredFields returns a copy of data without the values at the
+// JSONPaths of ignoredFields, such as "$.metadata.generation". "[*]" and ".*"
+// members match every element of an array and every member of an object, and
+// a numeric member also matches the array element at that index. A backslash
+// escapes the character that follows it in a member name, such as the dots
+// of "$.metadata.annotations.kubectl\.kubernetes\.io/last-applied-configuration".
+//
+// An error will be returned if a path does not end with a member name or
+// index, since there would be nothing left to compare.
+func removeIgnoredFields(data any, ignoredFields []Path) (any, error) {
+	paths := make([][]pathSegment, len(ignoredFields))
+	for i, path := range ignoredFields {
+		segments, err := ignoredFieldSegments(path)
 		if err != nil {
 			return nil, err
 		}
+		paths[i] = segments
 	}
-
-	var result any
-	err = json.Unmarshal([]byte(jsonStr), &result)
-	if err != nil {
-		return nil, err
+	result := deepCopy(data)
+	for _, segments := range paths {
+		result = removePath(result, segments)
 	}
-
 	return result, nil
 }
 
-func removeJSONPath(jsonStr, jsonPath string) (string, error) {
-	if strings.Contains(jsonPath, "[*]") {
-		return removeFromArrayElements(jsonStr, jsonPath)
-	}
-
-	path := strings.TrimPrefix(jsonPath, "$.")
-	path = strings.TrimPrefix(path, "$")
+// pathSegment is a member of an ignored field, or a wildcard matching every
+// member of an object or every element of an array.
+type pathSegment struct {
+	name       string
+	anyMember  bool
+	anyElement bool
+}
 
-	result, err := sjson.Delete(jsonStr, path)
-	if err != nil {
-		return "", err
+// ignoredFieldSegments splits an ignored field into its members, with their
+// escapes resolved. The leading "$." may be left out, as in "metadata.name".
+func ignoredFieldSegments(path Path) ([]pathSegment, error) {
+	rest := strings.TrimPrefix(string(path), "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
 	}
-
-	return result, nil
+	segments := []pathSegment{}
+	for rest != "" {
+		if after, ok := strings.CutPrefix(rest, "[*]"); ok {
+			segments = append(segments, pathSegment{anyElement: true})
+			rest = after
+			continue
+		}
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid ignored field %q: array elements are written \"[*]\" and members \".name\"", path)
+		}
+		name := strings.Builder{}
+		end := 1
+		for ; end < len(rest) && rest[end] != '.' && rest[end] != '['; end++ {
+			if rest[end] == '\\' && end+1 < len(rest) {
+				end++
+			}
+			name.WriteByte(rest[end])
+		}
+		if end == 1 {
+			return nil, fmt.Errorf("invalid ignored field %q: empty member name", path)
+		}
+		segments = append(segments, pathSegment{name: name.String(), anyMember: rest[:end] == ".*"})
+		rest = rest[end:]
+	}
+	if len(segments) == 0 || segments[len(segments)-1].anyMember || segments[len(segments)-1].anyElement {
+		return nil, fmt.Errorf("invalid ignored field %q: paths end with a member name or index", path)
+	}
+	return segments, nil
 }
 
-func removeFromArrayElements(jsonStr, jsonPath string) (string, error) {
-	parts := strings.Split(jsonPath, "[*].")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid wildcard path format")
+// removePath removes the values at segments from node, which is modified,
+// and returns the result.
+func removePath(node any, segments []pathSegment) any {
+	if len(segments) == 0 {
+		return node
 	}
-
-	arrayPath := strings.TrimPrefix(parts[0], "$.")
-	arrayPath = strings.TrimPrefix(arrayPath, "$")
-	propertyToRemove := parts[1]
-
-	arrayResult := gjson.Get(jsonStr, arrayPath)
-	if !arrayResult.Exists() || !arrayResult.IsArray() {
-		return jsonStr, nil
+	segment, rest := segments[0], segments[1:]
+	switch n := node.(type) {
+	case map[string]any:
+		if segment.anyElement {
+			return n
+		}
+		for key, value := range n {
+			if key != segment.name && !segment.anyMember {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(n, key)
+			} else {
+				n[key] = removePath(value, rest)
+			}
+		}
+		return n
+	case []any:
+		if segment.anyElement {
+			for i, value := range n {
+				n[i] = removePath(value, rest)
+			}
+			return n
+		}
+		i, err := strconv.Atoi(segment.name)
+		if segment.anyMember || err != nil || i < 0 || i >= len(n) {
+			return n
+		}
+		if len(rest) == 0 {
+			return slices.Delete(n, i, i+1)
+		}
+		n[i] = removePath(n[i], rest)
+		return n
 	}
-
-	result := jsonStr
-	var err error
-
-	arrayResult.ForEach(func(key, value gjson.Result) bool {
-		elementPath := fmt.Sprintf("%s.%d.%s", arrayPath, key.Int(), propertyToRemove)
-		result, err = sjson.Delete(result, elementPath)
-		return err == nil
-	})
-
-	return result, err
+	return node
 }
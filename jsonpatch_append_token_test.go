@@ -0,0 +1,77 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendToken_SetAdditions_UseDash(t *testing.T) {
+	a := `{"s":[1, 2, 3]}`
+	b := `{"s":[3, 4, 1, 5]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch, WithAppendToken())
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/s/1", nil),
+		NewPatch("add", "/s/-", float64(4)),
+		NewPatch("add", "/s/-", float64(5)),
+	}, patch)
+}
+
+func TestAppendToken_EntitySetAdditions_UseDash(t *testing.T) {
+	a := `{"t":[{"k":1, "v":1}, {"k":2, "v":2}]}`
+	b := `{"t":[{"k":2, "v":3}, {"k":3, "v":3}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch, WithAppendToken())
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/t/0", nil),
+		NewPatch("replace", "/t/0/v", float64(3)),
+		NewPatch("add", "/t/-", map[string]any{"k": float64(3), "v": float64(3)}),
+	}, patch)
+}
+
+func TestAppendToken_Disabled_UsesIndexes(t *testing.T) {
+	a := `{"s":[1]}`
+	b := `{"s":[1, 2]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/s/1", float64(2))}, patch)
+}
+
+func TestAppendToken_Arrays_KeepIndexes(t *testing.T) {
+	a := `{"l":[1, 3]}`
+	b := `{"l":[1, 2, 3, 4]}`
+
+	collections := Collections{Arrays: []Path{"$.l"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch, WithAppendToken())
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("add", "/l/1", float64(2)),
+		NewPatch("add", "/l/3", float64(4)),
+	}, patch)
+}
+
+func TestAppendToken_PatchAppliesToGrownDocument(t *testing.T) {
+	a := `{"Tags":[{"Key":"Name", "Value":"a"}]}`
+	b := `{"Tags":[{"Key":"Name", "Value":"a"}, {"Key":"Env", "Value":"prod"}]}`
+	// Someone appended a tag between the diff and the apply.
+	stale := `{"Tags":[{"Key":"Name", "Value":"a"}, {"Key":"Team", "Value":"x"}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.Tags": "Key"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyEnsureExists, WithAppendToken())
+	require.NoError(t, err)
+
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(stale), &doc))
+	doc, err = applyPatch(doc, patch)
+	require.NoError(t, err)
+	patched, err := json.Marshal(doc)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Tags":[{"Key":"Name", "Value":"a"}, {"Key":"Team", "Value":"x"}, {"Key":"Env", "Value":"prod"}]}`, string(patched))
+}
@@ -0,0 +1,223 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// applyPatch applies patch to doc as specified in RFC 6902 and returns the
+// resulting document. doc is modified in place, so callers that need to keep
+// it must pass a copy. An error is returned for the first operation whose
+// path is not valid against the document at the time it is applied.
+func applyPatch(doc any, patch []JsonPatchOperation) (any, error) {
+	var err error
+	for _, op := range patch {
+		switch op.Operation {
+		case "add":
+			doc, err = pointerAdd(doc, op.Path, deepCopy(op.Value))
+		case "remove":
+			doc, _, err = pointerRemove(doc, op.Path)
+		case "replace":
+			doc, _, err = pointerRemove(doc, op.Path)
+			if err == nil {
+				doc, err = pointerAdd(doc, op.Path, deepCopy(op.Value))
+			}
+		case "move":
+			var value any
+			doc, value, err = pointerRemove(doc, op.From)
+			if err == nil {
+				doc, err = pointerAdd(doc, op.Path, value)
+			}
+		case "copy":
+			var value any
+			var ok bool
+			value, ok, err = pointerGet(doc, op.From)
+			if err == nil && !ok {
+				err = fmt.Errorf("%q not found", op.From)
+			}
+			if err == nil {
+				doc, err = pointerAdd(doc, op.Path, deepCopy(value))
+			}
+		case "test":
+			var value any
+			value, _, err = pointerGet(doc, op.Path)
+			if err == nil && !matchesValue(value, op.Value, false) {
+				err = fmt.Errorf("test failed")
+			}
+		default:
+			err = fmt.Errorf("unsupported operation %q", op.Operation)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Operation, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// deepCopy returns a copy of a json value that shares no maps or slices with it.
+func deepCopy(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(t))
+		for key, value := range t {
+			result[key] = deepCopy(value)
+		}
+		return result
+	case []any:
+		result := make([]any, len(t))
+		for i, value := range t {
+			result[i] = deepCopy(value)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func pointerTokens(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = rfc6901Decoder.Replace(token)
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("index %q out of range", token)
+	}
+	return i, nil
+}
+
+// pointerGet returns the value at pointer and whether it exists. Only the
+// last token may be missing.
+func pointerGet(doc any, pointer string) (any, bool, error) {
+	tokens, err := pointerTokens(pointer)
+	if err != nil {
+		return nil, false, err
+	}
+	node := doc
+	for i, token := range tokens {
+		var ok bool
+		switch n := node.(type) {
+		case map[string]any:
+			node, ok = n[token]
+		case []any:
+			index, err := strconv.Atoi(token)
+			ok = err == nil && index >= 0 && index < len(n)
+			if ok {
+				node = n[index]
+			}
+		}
+		if !ok {
+			if i == len(tokens)-1 {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("member %q not found", token)
+		}
+	}
+	return node, true, nil
+}
+
+// pointerUpdate walks to the parent of pointer and calls update with the
+// container and the last token, replacing the container with the result.
+func pointerUpdate(doc any, pointer string, update func(parent any, token string) (any, error)) (any, error) {
+	tokens, err := pointerTokens(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return update(nil, "")
+	}
+	var walk func(node any, tokens []string) (any, error)
+	walk = func(node any, tokens []string) (any, error) {
+		if len(tokens) == 1 {
+			return update(node, tokens[0])
+		}
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[tokens[0]]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", tokens[0])
+			}
+			child, err := walk(child, tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[tokens[0]] = child
+			return n, nil
+		case []any:
+			i, err := arrayIndex(tokens[0], len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			child, err := walk(n[i], tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[i] = child
+			return n, nil
+		}
+		return nil, fmt.Errorf("cannot traverse %T", node)
+	}
+	return walk(doc, tokens)
+}
+
+func pointerAdd(doc any, pointer string, value any) (any, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	return pointerUpdate(doc, pointer, func(parent any, token string) (any, error) {
+		switch n := parent.(type) {
+		case map[string]any:
+			n[token] = value
+			return n, nil
+		case []any:
+			i, err := arrayIndex(token, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			return append(n[:i:i], append([]any{value}, n[i:]...)...), nil
+		}
+		return nil, fmt.Errorf("cannot add to %T", parent)
+	})
+}
+
+func pointerRemove(doc any, pointer string) (any, any, error) {
+	if pointer == "" {
+		return nil, doc, nil
+	}
+	var removed any
+	doc, err := pointerUpdate(doc, pointer, func(parent any, token string) (any, error) {
+		switch n := parent.(type) {
+		case map[string]any:
+			value, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			removed = value
+			delete(n, token)
+			return n, nil
+		case []any:
+			i, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = n[i]
+			return append(n[:i:i], n[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove from %T", parent)
+	})
+	return doc, removed, err
+}
@@ -1,10 +1,13 @@
 package jsonpatch
 
 import (
+	"encoding/json"
+	"math/rand"
 	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -59,7 +62,6 @@ var (
 
 // TestArrayRemoveSpaceInbetween tests removing one blank item from a group blanks which is in between non blank items which also end with a blank item. This tests that the correct index is removed
 func TestArrayRemoveSpaceInbetween(t *testing.T) {
-	t.Skip("This test fails. TODO change compareArray algorithm to match by index instead of by object equality")
 	patch, e := CreatePatch([]byte(arrayWithSpacesBase), []byte(arrayWithSpacesUpdated), arrayTestCollections, nil, PatchStrategyExactMatch)
 	assert.NoError(t, e)
 	t.Log("Patch:", patch)
@@ -94,3 +96,84 @@ func TestArrayRemoveMulti(t *testing.T) {
 	assert.Equal(t, "/persons/1", change.Path, "they should be equal")
 	assert.Equal(t, nil, change.Value, "they should be equal")
 }
+
+var sequenceTestCollections = Collections{
+	Arrays: []Path{"$.containers", "$.containers[*].ports"},
+}
+
+func TestArrayAppendAndNestedChange_GeneratesMinimalOps(t *testing.T) {
+	a := `{"containers":[{"name":"app", "image":"app:1"}, {"name":"sidecar", "image":"proxy:1"}]}`
+	b := `{"containers":[{"name":"app", "image":"app:2"}, {"name":"sidecar", "image":"proxy:1"}, {"name":"log", "image":"log:1"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), sequenceTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/containers/0/image", "app:2"),
+		NewPatch("add", "/containers/2", map[string]any{"name": "log", "image": "log:1"}),
+	}, patch)
+}
+
+func TestArrayInsertInTheMiddle_GeneratesSingleAdd(t *testing.T) {
+	a := `{"containers":[{"name":"a"}, {"name":"c"}, {"name":"d"}]}`
+	b := `{"containers":[{"name":"a"}, {"name":"b"}, {"name":"c"}, {"name":"d"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), sequenceTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/containers/1", map[string]any{"name": "b"})}, patch)
+}
+
+func TestArrayRemoveAndNestedChange_GeneratesMinimalOps(t *testing.T) {
+	a := `{"containers":[{"name":"a"}, {"name":"b"}, {"name":"c", "ports":[80]}]}`
+	b := `{"containers":[{"name":"a"}, {"name":"c", "ports":[80, 443]}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), sequenceTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/containers/1", nil),
+		NewPatch("add", "/containers/1/ports/1", float64(443)),
+	}, patch)
+}
+
+func TestArrayGrowsInEnsureExistsMode_KeepsExistingElements(t *testing.T) {
+	a := `{"containers":[{"name":"a"}, {"name":"x"}, {"name":"y"}]}`
+	b := `{"containers":[{"name":"a"}, {"name":"b"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), sequenceTestCollections, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/containers/3", map[string]any{"name": "b"})}, patch)
+}
+
+func TestArrayOfScalarsInEnsureExistsMode_AppendsMissingElements(t *testing.T) {
+	collections := Collections{Arrays: []Path{"$.l"}}
+	patch, err := CreatePatch([]byte(`{"l":[1, 2, 3]}`), []byte(`{"l":[1, 5]}`), collections, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/l/3", float64(5))}, patch)
+}
+
+func TestArrayInEnsureAbsentMode_GeneratesNoOps(t *testing.T) {
+	collections := Collections{Arrays: []Path{"$.l"}}
+	patch, err := CreatePatch([]byte(`{"l":[1, 2, 3]}`), []byte(`{"l":[1, 5]}`), collections, nil, PatchStrategyEnsureAbsent)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestArraySequencePatchesApplyAndConverge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomDoc := func() []byte {
+		containers := []any{}
+		for range r.Intn(7) {
+			ports := []any{}
+			for range r.Intn(3) {
+				ports = append(ports, float64(r.Intn(3)))
+			}
+			containers = append(containers, map[string]any{"name": float64(r.Intn(4)), "ports": ports})
+		}
+		doc, err := json.Marshal(map[string]any{"containers": containers})
+		require.NoError(t, err)
+		return doc
+	}
+
+	for range 500 {
+		assertPatchConverges(t, randomDoc(), randomDoc(), sequenceTestCollections, PatchStrategyExactMatch)
+	}
+}
@@ -144,3 +144,152 @@ func TestAtomicField_ExtraKeysInActual_SingleReplace(t *testing.T) {
 		t.Errorf("expected path /Policy, got %s", patch[0].Path)
 	}
 }
+
+func TestAtomicArray_DifferentElements_SingleReplace(t *testing.T) {
+	a := `{"SubnetIds": ["subnet-1", "subnet-2"]}`
+	b := `{"SubnetIds": ["subnet-1", "subnet-3", "subnet-4"]}`
+
+	collections := Collections{
+		Atomics: []Path{"$.SubnetIds"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 1 {
+		t.Fatalf("expected 1 patch operation, got %d: %v", len(patch), patch)
+	}
+	if patch[0].Operation != "replace" {
+		t.Errorf("expected replace, got %s", patch[0].Operation)
+	}
+	if patch[0].Path != "/SubnetIds" {
+		t.Errorf("expected path /SubnetIds, got %s", patch[0].Path)
+	}
+}
+
+func TestAtomicArray_ReorderedElements_SingleReplace(t *testing.T) {
+	// Atomics compare order-sensitively, even where the array would be a set otherwise
+	a := `{"SubnetIds": ["subnet-1", "subnet-2"]}`
+	b := `{"SubnetIds": ["subnet-2", "subnet-1"]}`
+
+	collections := Collections{
+		Atomics: []Path{"$.SubnetIds"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 1 {
+		t.Fatalf("expected 1 patch operation, got %d: %v", len(patch), patch)
+	}
+	if patch[0].Path != "/SubnetIds" {
+		t.Errorf("expected path /SubnetIds, got %s", patch[0].Path)
+	}
+}
+
+func TestUnorderedAtomicArray_ReorderedElements_NoPatch(t *testing.T) {
+	a := `{"SubnetIds": ["subnet-1", "subnet-2"], "Rules": [{"Ports": [80, 443]}]}`
+	b := `{"SubnetIds": ["subnet-2", "subnet-1"], "Rules": [{"Ports": [443, 80]}]}`
+
+	collections := Collections{
+		UnorderedAtomics: []Path{"$.SubnetIds", "$.Rules"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 0 {
+		t.Fatalf("expected 0 patch operations, got %d: %v", len(patch), patch)
+	}
+}
+
+func TestUnorderedAtomicArray_DifferentElements_SingleReplace(t *testing.T) {
+	a := `{"SubnetIds": ["subnet-1", "subnet-2"]}`
+	b := `{"SubnetIds": ["subnet-2"]}`
+
+	collections := Collections{
+		UnorderedAtomics: []Path{"$.SubnetIds"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyEnsureExists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 1 {
+		t.Fatalf("expected 1 patch operation, got %d: %v", len(patch), patch)
+	}
+	if patch[0].Operation != "replace" || patch[0].Path != "/SubnetIds" {
+		t.Errorf("expected replace of /SubnetIds, got %s %s", patch[0].Operation, patch[0].Path)
+	}
+}
+
+func TestAtomicArray_InsideArray_SingleReplace(t *testing.T) {
+	a := `{"Rules": [{"Name": "a", "Cidrs": ["10.0.0.0/8"]}, {"Name": "b", "Cidrs": ["10.1.0.0/16"]}]}`
+	b := `{"Rules": [{"Name": "a", "Cidrs": ["10.0.0.0/8"]}, {"Name": "b", "Cidrs": ["10.1.0.0/16", "10.2.0.0/16"]}]}`
+
+	collections := Collections{
+		Arrays:  []Path{"$.Rules"},
+		Atomics: []Path{"$.Rules[*].Cidrs"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 1 {
+		t.Fatalf("expected 1 patch operation, got %d: %v", len(patch), patch)
+	}
+	if patch[0].Operation != "replace" || patch[0].Path != "/Rules/1/Cidrs" {
+		t.Errorf("expected replace of /Rules/1/Cidrs, got %s %s", patch[0].Operation, patch[0].Path)
+	}
+}
+
+func TestAtomicField_MixedTypes_SingleReplace(t *testing.T) {
+	a := `{"Value": ["a", "b"]}`
+	b := `{"Value": "a,b"}`
+
+	collections := Collections{
+		Atomics: []Path{"$.Value"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 1 {
+		t.Fatalf("expected 1 patch operation, got %d: %v", len(patch), patch)
+	}
+	if patch[0].Operation != "replace" || patch[0].Value != "a,b" {
+		t.Errorf("expected replace with a,b, got %s %v", patch[0].Operation, patch[0].Value)
+	}
+}
+
+func TestAtomicField_AtRoot_SingleReplace(t *testing.T) {
+	a := `[1, 2, 3]`
+	b := `[1, 2, 4]`
+
+	collections := Collections{
+		Atomics: []Path{"$"},
+	}
+
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patch) != 1 {
+		t.Fatalf("expected 1 patch operation, got %d: %v", len(patch), patch)
+	}
+	if patch[0].Operation != "replace" || patch[0].Path != "" {
+		t.Errorf("expected replace of the root, got %s %q", patch[0].Operation, patch[0].Path)
+	}
+}
@@ -0,0 +1,141 @@
+package jsonpatch
+
+import "strings"
+
+// compactPatch folds runs of consecutive operations below a common container
+// into a single replace of that container, when the replace is cheaper. The
+// cost of operations is their serialized size, and a run is folded when the
+// replace costs at most ratio times as much. Deeper containers are folded
+// first, so a fold can itself become part of a run at a shallower level.
+//
+// doc is the original document the patch applies to. It is not modified.
+// Operations inside EntitySets and KeyedArrays are never folded, since their
+// items are identified by key rather than by position and replacing the
+// collection, or anything containing it, would replace them all.
+func compactPatch(doc any, patch []JsonPatchOperation, ratio float64, collections Collections) []JsonPatchOperation {
+	if ratio <= 0 || len(patch) < 2 {
+		return patch
+	}
+	depth := 0
+	for _, op := range patch {
+		depth = max(depth, len(strings.Split(op.Path, "/"))-1)
+	}
+	original := patch
+	for d := depth - 1; d >= 0; d-- {
+		// working is doc with patch[:i] applied, so each operation is
+		// applied once per level.
+		working := deepCopy(doc)
+		for i := 0; i < len(patch); {
+			prefix, ok := operationPrefix(patch[i], d, collections)
+			j := i + 1
+			for ok && j < len(patch) {
+				next, nextOk := operationPrefix(patch[j], d, collections)
+				if !nextOk || next != prefix {
+					break
+				}
+				j++
+			}
+			if j-i < 2 {
+				var err error
+				if working, err = applyPatch(working, patch[i:j]); err != nil {
+					return original
+				}
+				i = j
+				continue
+			}
+			var replace JsonPatchOperation
+			var fold bool
+			var err error
+			working, replace, fold, err = foldOperations(working, patch[i:j], prefix, ratio)
+			if err != nil {
+				return original
+			}
+			if fold {
+				patch = append(patch[:i:i], append([]JsonPatchOperation{replace}, patch[j:]...)...)
+				j = i + 1
+			}
+			// When the run is not folded, none of its suffixes would be either:
+			// they would be replaced by the same value and cost less.
+			i = j
+		}
+	}
+	return patch
+}
+
+// operationPrefix returns the pointer made of the first depth tokens of the
+// operation path, if the operation only touches values strictly below it and
+// does not reach them through an EntitySet or a KeyedArray.
+func operationPrefix(op JsonPatchOperation, depth int, collections Collections) (string, bool) {
+	prefix, ok := pointerPrefix(op.Path, depth, collections)
+	if !ok {
+		return "", false
+	}
+	if op.Operation == "move" || op.Operation == "copy" {
+		from, ok := pointerPrefix(op.From, depth, collections)
+		if !ok || from != prefix {
+			return "", false
+		}
+	}
+	return prefix, true
+}
+
+func pointerPrefix(pointer string, depth int, collections Collections) (string, bool) {
+	tokens := strings.Split(pointer, "/")
+	if pointer == "" || len(tokens) <= depth+1 {
+		return "", false
+	}
+	for i := depth + 1; i < len(tokens); i++ {
+		container := strings.Join(tokens[:i], "/")
+		if collections.isEntitySet(container) || collections.isKeyedArray(container) {
+			return "", false
+		}
+	}
+	return strings.Join(tokens[:depth+1], "/"), true
+}
+
+// foldOperations applies run to working, which is modified, and returns the
+// result along with the replace of prefix equivalent to run, if it is cheap
+// enough to fold it.
+func foldOperations(working any, run []JsonPatchOperation, prefix string, ratio float64) (any, JsonPatchOperation, bool, error) {
+	value, ok, err := pointerGet(working, prefix)
+	isContainer := false
+	if err == nil && ok {
+		switch value.(type) {
+		case map[string]any, []any:
+			isContainer = true
+		}
+	}
+	working, err = applyPatch(working, run)
+	if err != nil || !isContainer {
+		return working, JsonPatchOperation{}, false, err
+	}
+	value, ok, err = pointerGet(working, prefix)
+	if err != nil || !ok {
+		return working, JsonPatchOperation{}, false, nil
+	}
+	replace := NewPatch("replace", prefix, value)
+	replaceCost, err := operationsCost([]JsonPatchOperation{replace})
+	if err != nil {
+		return working, JsonPatchOperation{}, false, nil
+	}
+	runCost, err := operationsCost(run)
+	if err != nil || float64(replaceCost) > ratio*float64(runCost) {
+		return working, JsonPatchOperation{}, false, nil
+	}
+	// The value is still part of working, which later operations modify.
+	replace.Value = deepCopy(value)
+	return working, replace, true, nil
+}
+
+// operationsCost returns the serialized size of the operations.
+func operationsCost(patch []JsonPatchOperation) (int, error) {
+	cost := 0
+	for _, op := range patch {
+		b, err := op.MarshalJson()
+		if err != nil {
+			return 0, err
+		}
+		cost += len(b)
+	}
+	return cost, nil
+}
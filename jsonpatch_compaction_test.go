@@ -0,0 +1,143 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompaction_MostChildrenChanged_GeneratesParentReplace(t *testing.T) {
+	a := `{"name":"web", "limits":{"cpu":1, "memory":2, "disk":3, "pids":4}}`
+	b := `{"name":"web", "limits":{"cpu":5, "memory":6, "disk":7, "pids":8}}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch, WithCompactionRatio(1))
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/limits", map[string]any{"cpu": float64(5), "memory": float64(6), "disk": float64(7), "pids": float64(8)}),
+	}, patch)
+}
+
+func TestCompaction_FewChildrenChanged_KeepsOperations(t *testing.T) {
+	a := `{"limits":{"cpu":1, "memory":2, "description":"a rather long description of the limits"}}`
+	b := `{"limits":{"cpu":5, "memory":6, "description":"a rather long description of the limits"}}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch, WithCompactionRatio(1))
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/limits/cpu", float64(5)),
+		NewPatch("replace", "/limits/memory", float64(6)),
+	}, patch)
+}
+
+func TestCompaction_Disabled_KeepsOperations(t *testing.T) {
+	a := `{"limits":{"cpu":1, "memory":2}}`
+	b := `{"limits":{"cpu":5, "memory":6}}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Len(t, patch, 2)
+}
+
+func TestCompaction_EntitySet_NeverReplacedAsAWhole(t *testing.T) {
+	a := `{"t":[{"k":1, "v":1}, {"k":2, "v":2}]}`
+	b := `{"t":[{"k":1, "v":3}, {"k":2, "v":4}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch, WithCompactionRatio(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/t/0/v", float64(3)),
+		NewPatch("replace", "/t/1/v", float64(4)),
+	}, patch)
+}
+
+func TestCompaction_ChangesOutsideEntitySet_StillFolded(t *testing.T) {
+	a := `{"spec":{"cpu":1, "memory":2}, "t":[{"k":1, "v":1}, {"k":2, "v":2}]}`
+	b := `{"spec":{"cpu":5, "memory":6}, "t":[{"k":1, "v":3}, {"k":2, "v":2}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch, WithCompactionRatio(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/spec", map[string]any{"cpu": float64(5), "memory": float64(6)}),
+		NewPatch("replace", "/t/0/v", float64(3)),
+	}, patch)
+}
+
+func TestCompaction_IgnoredFields_KeptInReplacedValue(t *testing.T) {
+	a := `{"limits":{"cpu":1, "memory":2, "id":"x"}}`
+	b := `{"limits":{"cpu":5, "memory":6}}`
+
+	ignored := []Path{"$.limits.id"}
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, ignored, PatchStrategyExactMatch, WithCompactionRatio(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/limits", map[string]any{"cpu": float64(5), "memory": float64(6), "id": "x"}),
+	}, patch)
+}
+
+func TestCompaction_PatchesApplyToTheSameDocument(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomDoc := func() []byte {
+		doc := map[string]any{}
+		for _, key := range []string{"a", "b", "c", "d"} {
+			if r.Intn(4) == 0 {
+				continue
+			}
+			child := map[string]any{}
+			for _, member := range []string{"x", "y", "z"} {
+				if r.Intn(3) > 0 {
+					child[member] = float64(r.Intn(3))
+				}
+			}
+			items := []any{}
+			for range r.Intn(4) {
+				items = append(items, float64(r.Intn(3)))
+			}
+			child["l"] = items
+			doc[key] = child
+		}
+		b, err := json.Marshal(doc)
+		require.NoError(t, err)
+		return b
+	}
+	collections := Collections{Arrays: []Path{"$.a.l", "$.b.l"}}
+
+	for range 500 {
+		a, b := randomDoc(), randomDoc()
+		patch, err := CreatePatch(a, b, collections, nil, PatchStrategyExactMatch)
+		require.NoError(t, err)
+		compacted, err := CreatePatch(a, b, collections, nil, PatchStrategyExactMatch, WithCompactionRatio(1))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(compacted), len(patch))
+
+		var expected, actual any
+		require.NoError(t, json.Unmarshal(a, &expected))
+		require.NoError(t, json.Unmarshal(a, &actual))
+		expected, err = applyPatch(expected, patch)
+		require.NoError(t, err)
+		actual, err = applyPatch(actual, compacted)
+		require.NoError(t, err, "applying %v to %s", compacted, a)
+		assert.True(t, matchesValue(expected, actual, false), "%s patched with %v", a, compacted)
+	}
+}
+
+func BenchmarkCompactPatch(b *testing.B) {
+	items := make([]any, 1000)
+	for i := range items {
+		items[i] = map[string]any{"name": float64(i), "description": "an item that is left as it is", "values": []any{float64(i)}}
+	}
+	doc := map[string]any{"items": items}
+	patch := []JsonPatchOperation{}
+	for i := range items {
+		patch = append(patch, NewPatch("replace", makePath(makePath("/items", i), "name"), float64(-i)))
+		patch = append(patch, NewPatch("add", makePath(makePath("/items", i), "values")+"/1", float64(i)))
+	}
+	collections := Collections{Arrays: []Path{"$.items", "$.items[*].values"}}
+	for i := 0; i < b.N; i++ {
+		compactPatch(doc, patch, 0.5, collections)
+	}
+}
@@ -0,0 +1,207 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the options of CreatePatch, so that they can be kept in a
+// configuration file rather than in code. It is written as YAML or JSON:
+//
+//	strategy: exact-match
+//	ignoredFields: [$.metadata.generation]
+//	collections:
+//	  entitySets: {$.spec.containers: name}
+//	  arrays:
+//	    - $.spec.containers[*].args
+//	  nullHandling: absent
+//
+// The members of collections are named after the fields of Collections, in
+// lower camel case. Paths are JSONPaths as described by Path.
+type Config struct {
+	Collections   Collections   `json:"collections,omitempty" yaml:"collections,omitempty"`
+	IgnoredFields []Path        `json:"ignoredFields,omitempty" yaml:"ignoredFields,omitempty"`
+	Strategy      PatchStrategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+}
+
+// ConfigError is an invalid value at Line of a configuration file.
+type ConfigError struct {
+	Line    int
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration: line %d: %s", e.Line, e.Message)
+}
+
+// LoadCollections reads a Config from YAML or JSON. The strategy defaults to
+// PatchStrategyExactMatch, and Defaults are converted to the values
+// json.Unmarshal produces.
+//
+// An error will be returned if the configuration cannot be parsed, has
+// unknown members, or has invalid values. Errors point to the offending line.
+func LoadCollections(r io.Reader) (Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Config{}, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return Config{}, err
+	}
+	config := Config{}
+	if len(root.Content) > 0 {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := config.validate(&root); err != nil {
+		return Config{}, err
+	}
+	for path, value := range config.Collections.Defaults {
+		decoded, err := decodeValue(value)
+		if err != nil {
+			return Config{}, configError(&root, err.Error(), "collections", "defaults", string(path))
+		}
+		config.Collections.Defaults[path] = decoded
+	}
+	if config.Strategy == "" {
+		config.Strategy = PatchStrategyExactMatch
+	}
+	return config, nil
+}
+
+func (c Config) validate(root *yaml.Node) error {
+	collections := c.Collections
+	pathLists := []struct {
+		keys  []any
+		paths []Path
+	}{
+		{[]any{"ignoredFields"}, c.IgnoredFields},
+		{[]any{"collections", "arrays"}, collections.Arrays},
+		{[]any{"collections", "sets"}, collections.Sets},
+		{[]any{"collections", "atomics"}, collections.Atomics},
+		{[]any{"collections", "unorderedAtomics"}, collections.UnorderedAtomics},
+		{[]any{"collections", "emptyEquivalentPaths"}, collections.EmptyEquivalentPaths},
+		{[]any{"collections", "mutableKeys"}, collections.MutableKeys},
+		{[]any{"collections", "fuzzySets"}, collections.FuzzySets},
+	}
+	for _, list := range pathLists {
+		for i, path := range list.paths {
+			if message := validatePath(path); message != "" {
+				return configError(root, message, append(list.keys, i)...)
+			}
+		}
+	}
+	entitySets := []struct {
+		name string
+		sets EntitySets
+	}{
+		{"entitySets", collections.EntitySets},
+		{"keyedArrays", collections.KeyedArrays},
+	}
+	for _, entitySet := range entitySets {
+		for _, path := range slices.Sorted(maps.Keys(entitySet.sets)) {
+			if message := validatePath(path); message != "" {
+				return configError(root, message, "collections", entitySet.name, configKey(path))
+			}
+			if entitySet.sets[path] == "" {
+				return configError(root, fmt.Sprintf("missing key for %s", path), "collections", entitySet.name, string(path))
+			}
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(collections.Defaults)) {
+		if message := validatePath(path); message != "" {
+			return configError(root, message, "collections", "defaults", configKey(path))
+		}
+	}
+
+	switch collections.NullHandling {
+	case "", NullAsValue, NullAsDelete, NullAsAbsent:
+	default:
+		return configError(root, fmt.Sprintf("unknown nullHandling %q, expected %q, %q or %q", collections.NullHandling, NullAsValue, NullAsDelete, NullAsAbsent), "collections", "nullHandling")
+	}
+	switch c.Strategy {
+	case "", PatchStrategyExactMatch, PatchStrategyEnsureExists, PatchStrategyEnsureAbsent:
+	default:
+		return configError(root, fmt.Sprintf("unknown strategy %q, expected %q, %q or %q", c.Strategy, PatchStrategyExactMatch, PatchStrategyEnsureExists, PatchStrategyEnsureAbsent), "strategy")
+	}
+	if collections.SimilarityThreshold < 0 || collections.SimilarityThreshold > 1 {
+		return configError(root, "similarityThreshold must be between 0 and 1", "collections", "similarityThreshold")
+	}
+	return nil
+}
+
+// validatePath returns why path is not a JSONPath as described by Path, or
+// an empty string if it is one.
+func validatePath(path Path) string {
+	if !strings.HasPrefix(string(path), "$") {
+		return fmt.Sprintf("invalid path %q: paths start with \"$\"", path)
+	}
+	for _, segment := range jsonPathSegments(string(path)) {
+		if segment != "[*]" && (!strings.HasPrefix(segment, ".") || segment == ".") {
+			return fmt.Sprintf("invalid path %q: array elements are written \"[*]\" and members \".name\"", path)
+		}
+	}
+	return ""
+}
+
+// configKey addresses the key, rather than the value, of a member in
+// configNode.
+type configKey string
+
+// configNode returns the node of the configuration reached through keys,
+// which are member names, configKeys, or indexes of sequences. It returns
+// nil if there is none.
+func configNode(root *yaml.Node, keys ...any) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		var next *yaml.Node
+		switch k := key.(type) {
+		case int:
+			if node.Kind == yaml.SequenceNode && k < len(node.Content) {
+				next = node.Content[k]
+			}
+		case string, configKey:
+			if node.Kind != yaml.MappingNode {
+				break
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == fmt.Sprint(k) {
+					next = node.Content[i+1]
+					if _, ok := k.(configKey); ok {
+						next = node.Content[i]
+					}
+					break
+				}
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// configError returns a ConfigError at the line of the node reached through
+// keys, or of the closest node to it that exists.
+func configError(root *yaml.Node, message string, keys ...any) error {
+	for n := len(keys); n >= 0; n-- {
+		if node := configNode(root, keys[:n]...); node != nil {
+			return &ConfigError{Line: node.Line, Message: message}
+		}
+	}
+	return &ConfigError{Message: message}
+}
@@ -0,0 +1,157 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var configTestYaml = `
+strategy: exact-match
+ignoredFields:
+  - $.metadata.generation
+  - $.items[*].status
+collections:
+  entitySets:
+    $.spec.containers: name
+  keyedArrays:
+    $.spec.steps: id
+  arrays:
+    - $.spec.containers[*].args
+  sets: [$.metadata.finalizers]
+  atomics: [$.spec.selector]
+  unorderedAtomics: [$.spec.policy]
+  nullHandling: absent
+  emptyEquivalentPaths: [$.spec.volumes]
+  mutableKeys: [$.spec.containers]
+  fuzzySets: [$.spec.rules]
+  similarityThreshold: 0.7
+  defaults:
+    $.spec.replicas: 1
+    $.spec.labels.*: {enabled: true}
+`
+
+var configTestExpected = Config{
+	Strategy:      PatchStrategyExactMatch,
+	IgnoredFields: []Path{"$.metadata.generation", "$.items[*].status"},
+	Collections: Collections{
+		EntitySets:           EntitySets{"$.spec.containers": "name"},
+		KeyedArrays:          EntitySets{"$.spec.steps": "id"},
+		Arrays:               []Path{"$.spec.containers[*].args"},
+		Sets:                 []Path{"$.metadata.finalizers"},
+		Atomics:              []Path{"$.spec.selector"},
+		UnorderedAtomics:     []Path{"$.spec.policy"},
+		NullHandling:         NullAsAbsent,
+		EmptyEquivalentPaths: []Path{"$.spec.volumes"},
+		MutableKeys:          []Path{"$.spec.containers"},
+		FuzzySets:            []Path{"$.spec.rules"},
+		SimilarityThreshold:  0.7,
+		Defaults:             map[Path]any{"$.spec.replicas": float64(1), "$.spec.labels.*": map[string]any{"enabled": true}},
+	},
+}
+
+func TestLoadCollections_Yaml(t *testing.T) {
+	config, err := LoadCollections(strings.NewReader(configTestYaml))
+	require.NoError(t, err)
+	assert.Equal(t, configTestExpected, config)
+}
+
+func TestLoadCollections_Json(t *testing.T) {
+	config, err := LoadCollections(strings.NewReader(`{
+	"collections": {
+		"entitySets": {"$.spec.containers": "name"},
+		"arrays": ["$.spec.containers[*].args"],
+		"defaults": {"$.spec.replicas": 1}
+	},
+	"ignoredFields": ["$.metadata.generation"]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Strategy:      PatchStrategyExactMatch,
+		IgnoredFields: []Path{"$.metadata.generation"},
+		Collections: Collections{
+			EntitySets: EntitySets{"$.spec.containers": "name"},
+			Arrays:     []Path{"$.spec.containers[*].args"},
+			Defaults:   map[Path]any{"$.spec.replicas": float64(1)},
+		},
+	}, config)
+}
+
+func TestLoadCollections_Empty_DefaultsStrategy(t *testing.T) {
+	config, err := LoadCollections(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Equal(t, Config{Strategy: PatchStrategyExactMatch}, config)
+}
+
+func TestLoadCollections_RoundTrips(t *testing.T) {
+	yamlBytes, err := yaml.Marshal(configTestExpected)
+	require.NoError(t, err)
+	config, err := LoadCollections(bytes.NewReader(yamlBytes))
+	require.NoError(t, err)
+	assert.Equal(t, configTestExpected, config)
+
+	jsonBytes, err := json.Marshal(configTestExpected)
+	require.NoError(t, err)
+	config, err = LoadCollections(bytes.NewReader(jsonBytes))
+	require.NoError(t, err)
+	assert.Equal(t, configTestExpected, config)
+}
+
+func TestLoadCollections_Invalid_ReportsLine(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		line    int
+		message string
+	}{
+		{"path", "collections:\n  arrays:\n    - $.a\n    - a.b\n", 4, `invalid path "a.b"`},
+		{"index", "ignoredFields:\n  - $.a[0]\n", 2, `invalid path "$.a[0]"`},
+		{"entity set path", "collections:\n  entitySets:\n    $.a: id\n    b: id\n", 4, `invalid path "b"`},
+		{"entity set key", "collections:\n  entitySets:\n    $.a:\n", 3, "missing key for $.a"},
+		{"default path", "collections:\n  defaults:\n    $.a: 1\n    $: 2\n    x: 3\n", 5, `invalid path "x"`},
+		{"null handling", "collections:\n  nullHandling: ignore\n", 2, `unknown nullHandling "ignore"`},
+		{"strategy", "\nstrategy: merge\n", 2, `unknown strategy "merge"`},
+		{"similarity threshold", "collections:\n\n  similarityThreshold: 1.5\n", 3, "similarityThreshold must be between 0 and 1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadCollections(strings.NewReader(c.config))
+			var configError *ConfigError
+			require.True(t, errors.As(err, &configError), "%v", err)
+			assert.Equal(t, c.line, configError.Line)
+			assert.Contains(t, configError.Message, c.message)
+		})
+	}
+}
+
+func TestLoadCollections_UnknownOrMistypedField_ReportsLine(t *testing.T) {
+	_, err := LoadCollections(strings.NewReader("collections:\n  arrays: [$.a]\n  atomic: [$.b]\n"))
+	assert.ErrorContains(t, err, "line 3")
+
+	_, err = LoadCollections(strings.NewReader("collections:\n  emptyEquivalence: [true]\n"))
+	assert.ErrorContains(t, err, "line 2")
+
+	// Patch options are given to CreatePatch, not configured.
+	_, err = LoadCollections(strings.NewReader("collections:\n  maxOperations: 10\n"))
+	assert.ErrorContains(t, err, "line 2")
+
+	_, err = LoadCollections(strings.NewReader("collections: [\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadCollections_DrivesCreatePatch(t *testing.T) {
+	config, err := LoadCollections(strings.NewReader(configTestYaml))
+	require.NoError(t, err)
+
+	a := `{"metadata":{"generation":1}, "spec":{"containers":[{"name":"a", "image":"x"}, {"name":"b", "image":"y"}]}}`
+	b := `{"metadata":{"generation":2}, "spec":{"replicas":1, "containers":[{"name":"b", "image":"y"}, {"name":"a", "image":"z"}]}}`
+	patch, err := CreatePatch([]byte(a), []byte(b), config.Collections, config.IgnoredFields, config.Strategy)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/spec/containers/0/image", "z")}, patch)
+}
@@ -0,0 +1,71 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultsTestCollections = Collections{
+	EntitySets: EntitySets{"$.Listeners": "Port"},
+	Defaults: map[Path]any{
+		"$.Enabled":              false,
+		"$.Timeout":              30,
+		"$.Listeners[*].Timeout": 60,
+		"$.Ports":                []int{80, 443},
+	},
+}
+
+func TestDefaults_DefaultOnlyInDesired_NoPatch(t *testing.T) {
+	a := `{"Name":"lb"}`
+	b := `{"Name":"lb", "Enabled":false, "Timeout":30, "Ports":[443, 80]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), defaultsTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestDefaults_DefaultOnlyInActual_NoPatch(t *testing.T) {
+	a := `{"Name":"lb", "Enabled":false, "Timeout":30}`
+	b := `{"Name":"lb"}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), defaultsTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestDefaults_NonDefaultValue_GeneratesAdd(t *testing.T) {
+	a := `{"Name":"lb"}`
+	b := `{"Name":"lb", "Enabled":true, "Timeout":30}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), defaultsTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/Enabled", true)}, patch)
+}
+
+func TestDefaults_PresentOnBothSides_StillDiffed(t *testing.T) {
+	a := `{"Timeout":45}`
+	b := `{"Timeout":30}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), defaultsTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/Timeout", float64(30))}, patch)
+}
+
+func TestDefaults_InsideEntitySetItems_NoPatch(t *testing.T) {
+	a := `{"Listeners":[{"Port":80}, {"Port":443, "Timeout":10}]}`
+	b := `{"Listeners":[{"Port":443, "Timeout":10}, {"Port":80, "Timeout":60}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), defaultsTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestDefaults_NotDeclared_GeneratesAdd(t *testing.T) {
+	a := `{"Name":"lb"}`
+	b := `{"Name":"lb", "Enabled":false}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/Enabled", false)}, patch)
+}
@@ -0,0 +1,79 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmptyEquivalence_EmptyDesiredVsAbsentActual_NoPatch(t *testing.T) {
+	a := `{"Name":"bucket"}`
+	b := `{"Name":"bucket", "Tags":[], "Labels":{}, "Owner":null}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{EmptyEquivalence: true}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestEmptyEquivalence_MixedEmptyKinds_NoPatch(t *testing.T) {
+	a := `{"Tags":null, "Labels":[], "Rules":{}}`
+	b := `{"Tags":[], "Labels":{}, "Rules":null}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{EmptyEquivalence: true}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestEmptyEquivalence_NonEmptyValues_StillDiffed(t *testing.T) {
+	a := `{"Tags":[], "Labels":null}`
+	b := `{"Tags":["x"], "Labels":{"a":"b"}}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{EmptyEquivalence: true}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/Labels", map[string]any{"a": "b"}),
+		NewPatch("add", "/Tags/0", "x"),
+	}, patch)
+}
+
+func TestEmptyEquivalence_Disabled_GeneratesAdd(t *testing.T) {
+	a := `{"Name":"bucket"}`
+	b := `{"Name":"bucket", "Tags":[]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/Tags", []any{})}, patch)
+}
+
+func TestEmptyEquivalentPaths_OnlyListedPathsAreEquivalent(t *testing.T) {
+	a := `{"Nested":{"Name":"x"}}`
+	b := `{"Tags":[], "Nested":{"Name":"x", "Tags":[], "Labels":{}}}`
+
+	collections := Collections{EmptyEquivalentPaths: []Path{"$.Tags", "$.Nested.Tags"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/Nested/Labels", map[string]any{})}, patch)
+}
+
+func TestEmptyEquivalence_EmptyEntitySetAbsentOnDesiredSide_NoRemove(t *testing.T) {
+	a := `{"a":100, "t":[]}`
+	b := `{"a":100}`
+
+	collections := Collections{
+		EntitySets:           EntitySets{"$.t": "k"},
+		EmptyEquivalentPaths: []Path{"$.t"},
+	}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestEmptyEquivalence_NullAsDeleteOnEmptyActual_NoRemove(t *testing.T) {
+	a := `{"Tags":[]}`
+	b := `{"Tags":null}`
+
+	collections := Collections{EmptyEquivalence: true, NullHandling: NullAsDelete}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
@@ -0,0 +1,106 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var entitySetPropertyCollections = Collections{
+	EntitySets: EntitySets{
+		Path("$.t"):      Key("k"),
+		Path("$.t[*].n"): Key("nk"),
+	},
+}
+
+// randomEntitySetDoc returns a document with an EntitySet of items drawn from
+// a small key space, so that two random documents share some of their items.
+func randomEntitySetDoc(r *rand.Rand) map[string]any {
+	items := []any{}
+	for _, k := range r.Perm(6)[:r.Intn(6)] {
+		nested := []any{}
+		for _, nk := range r.Perm(4)[:r.Intn(4)] {
+			nested = append(nested, map[string]any{"nk": float64(nk), "c": float64(r.Intn(2))})
+		}
+		items = append(items, map[string]any{"k": float64(k), "v": float64(r.Intn(3)), "n": nested})
+	}
+	return map[string]any{"t": items}
+}
+
+// applyCreatedPatch applies the patch from a to b, as CreatePatch generates
+// it, to a and returns the patch and the patched document.
+func applyCreatedPatch(t *testing.T, a, b []byte, collections Collections, strategy PatchStrategy) ([]JsonPatchOperation, []byte) {
+	t.Helper()
+	patch, err := CreatePatch(a, b, collections, nil, strategy)
+	require.NoError(t, err)
+
+	var doc any
+	require.NoError(t, json.Unmarshal(a, &doc))
+	doc, err = applyPatch(doc, patch)
+	require.NoError(t, err, "%s: applying %v to %s", strategy, patch, a)
+	patched, err := json.Marshal(doc)
+	require.NoError(t, err)
+	return patch, patched
+}
+
+// assertPatchConverges checks that the patch from a to b brings a in the state
+// b describes: diffing the patched document against b again must produce no
+// operations, and in ExactMatch mode the patched document must equal b.
+func assertPatchConverges(t *testing.T, a, b []byte, collections Collections, strategy PatchStrategy) {
+	t.Helper()
+	patch, patched := applyCreatedPatch(t, a, b, collections, strategy)
+
+	again, err := CreatePatch(patched, b, collections, nil, strategy)
+	require.NoError(t, err)
+	assert.Empty(t, again, "%s: %s patched with %v to %s does not converge to %s", strategy, a, patch, patched, b)
+
+	if strategy == PatchStrategyExactMatch {
+		var expected, actual any
+		require.NoError(t, json.Unmarshal(b, &expected))
+		require.NoError(t, json.Unmarshal(patched, &actual))
+		assert.True(t, matchesValue(expected, actual, true), "%s patched with %v is %s, expected %s", a, patch, patched, b)
+	}
+}
+
+func TestCreatePatch_EntitySetPatchesApplyAndConverge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, strategy := range []PatchStrategy{PatchStrategyExactMatch, PatchStrategyEnsureExists} {
+		for range 500 {
+			a, err := json.Marshal(randomEntitySetDoc(r))
+			require.NoError(t, err)
+			b, err := json.Marshal(randomEntitySetDoc(r))
+			require.NoError(t, err)
+			assertPatchConverges(t, a, b, entitySetPropertyCollections, strategy)
+		}
+	}
+}
+
+func TestCreatePatch_ModifyItemsAfterRemovedItems_UsesIndexInPatchedDocument(t *testing.T) {
+	a := `{"t":[{"k":1, "v":1}, {"k":2, "v":2}, {"k":3, "v":3}, {"k":4, "v":4}]}`
+	b := `{"t":[{"k":4, "v":5}, {"k":2, "v":5}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), entitySetPropertyCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/t/2", nil),
+		NewPatch("remove", "/t/0", nil),
+		NewPatch("replace", "/t/0/v", float64(5)),
+		NewPatch("replace", "/t/1/v", float64(5)),
+	}, patch)
+}
+
+func TestCreatePatch_DuplicateKeysInEntitySet_MatchedOnce(t *testing.T) {
+	a := `{"t":[{"k":1, "v":1}, {"k":1, "v":2}]}`
+	b := `{"t":[{"k":1, "v":3}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), entitySetPropertyCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/t/1", nil),
+		NewPatch("replace", "/t/0/v", float64(3)),
+	}, patch)
+}
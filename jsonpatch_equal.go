@@ -0,0 +1,187 @@
+package jsonpatch
+
+import "reflect"
+
+// Equal reports whether CreatePatch would generate no operations for 'a' and
+// 'b', that is whether the original document 'a' already is in the state
+// described by the modified document 'b'. It honours the same collections,
+// ignored fields and strategy, but stops at the first difference and does not
+// build the patch.
+//
+// An error will be returned if any of the two documents are invalid.
+func Equal(a, b []byte, collections Collections, ignoredFields []Path, strategy PatchStrategy) (bool, error) {
+	_, aWithoutIgnoredFields, bWithoutIgnoredFields, err := prepareDocuments(a, b, collections, ignoredFields)
+	if err != nil {
+		return false, err
+	}
+	return equalValues(aWithoutIgnoredFields, bWithoutIgnoredFields, "", strategy, collections, patchOptions{}), nil
+}
+
+// equalValues mirrors handleValues, returning false where it would generate
+// operations.
+func equalValues(av, bv any, p string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	if reflect.TypeOf(av) != reflect.TypeOf(bv) {
+		return false
+	}
+	if collections.isAtomic(p) {
+		return matchesValue(av, bv, collections.isUnorderedAtomic(p))
+	}
+	switch at := av.(type) {
+	case map[string]any:
+		return equalObjects(at, bv.(map[string]any), p, strategy, collections, options)
+	case []any:
+		bt := bv.([]any)
+		switch {
+		case collections.isKeyedArray(p):
+			return matchesValue(at, bt, false) || equalKeyedArrays(at, bt, p, strategy, collections, options)
+		case collections.isArray(p) && len(at) != len(bt):
+			return equalSequences(at, bt, p, strategy, collections, options)
+		case collections.isArray(p):
+			for i := range bt {
+				if !equalValues(at[i], bt[i], makePath(p, i), strategy, collections, options) {
+					return false
+				}
+			}
+			return true
+		case collections.isEntitySet(p):
+			return matchesValue(at, bt, true) || equalEntitySets(at, bt, p, strategy, collections, options)
+		default:
+			return matchesValue(at, bt, true) || equalSets(at, bt, p, strategy, collections, options)
+		}
+	}
+	return matchesValue(av, bv, false)
+}
+
+// equalObjects mirrors diff.
+func equalObjects(a, b map[string]any, path string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	for key, bv := range b {
+		p := makePath(path, key)
+		av, ok := a[key]
+		switch {
+		case isEmpty(bv) && (!ok || isEmpty(av)) && collections.isEmptyEquivalent(p):
+		case bv == nil && collections.NullHandling == NullAsDelete:
+			if ok && av != nil {
+				return false
+			}
+		case !ok:
+			if !collections.isDefault(p, bv) {
+				return false
+			}
+		default:
+			if !equalValues(av, bv, p, strategy, collections, options) {
+				return false
+			}
+		}
+	}
+	if strategy == PatchStrategyExactMatch {
+		for key, av := range a {
+			if _, found := b[key]; found {
+				continue
+			}
+			p := makePath(path, key)
+			if isEmpty(av) && collections.isEmptyEquivalent(p) || collections.isDefault(p, av) {
+				continue
+			}
+			if collections.isEntitySet(p) || options.exactMembers {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// equalEntitySets mirrors the EntitySet case of compareArray.
+func equalEntitySets(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	key, _ := collections.EntitySets.Get(Path(toJsonPath(p)))
+	matches := processIdentitySet(av, bv, key)
+	if strategy == PatchStrategyExactMatch && collections.isMutableKey(p) {
+		pairRenamedEntities(av, bv, key, matches, collections.similarityThreshold())
+	}
+	return equalMatches(av, bv, matches, key, p, strategy, collections, options)
+}
+
+// equalKeyedArrays mirrors compareKeyedArray. Matched items need no move when
+// they already are in the same order as in `bv`.
+func equalKeyedArrays(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	key, _ := collections.KeyedArrays.Get(Path(toJsonPath(p)))
+	matches := processIdentitySet(av, bv, key)
+	last := -1
+	for _, j := range matches {
+		if j < 0 {
+			continue
+		}
+		if j < last {
+			return false
+		}
+		last = j
+	}
+	return equalMatches(av, bv, matches, key, p, strategy, collections, options)
+}
+
+// equalMatches reports whether no item of `av` needs to be removed, no item of
+// `bv` needs to be added and the matched items are equal. Items matched
+// despite different keys have been renamed, and must have the same members.
+func equalMatches(av, bv []any, matches []int, key Key, p string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	found := 0
+	for i, j := range matches {
+		if j < 0 {
+			if strategy == PatchStrategyExactMatch {
+				return false
+			}
+			continue
+		}
+		found++
+		itemOptions := options
+		if entityIdentity(av[i], key) != entityIdentity(bv[j], key) {
+			itemOptions.exactMembers = true
+		}
+		if !equalValues(av[i], bv[j], makePath(p, i), strategy, collections, itemOptions) {
+			return false
+		}
+	}
+	return found == len(bv)
+}
+
+// equalSequences mirrors compareSequence for arrays of different lengths.
+func equalSequences(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	switch {
+	case strategy == PatchStrategyExactMatch:
+		return false
+	case strategy == PatchStrategyEnsureExists && len(av) < len(bv):
+		return false
+	}
+	// Otherwise it depends on which elements are paired when aligning the
+	// arrays, so this falls back to generating the operations.
+	return len(compareSequence(av, bv, p, strategy, collections, options)) == 0
+}
+
+// equalSets mirrors the default case of compareArray.
+func equalSets(av, bv []any, p string, strategy PatchStrategy, collections Collections, options patchOptions) bool {
+	removed := []int{}
+	if strategy == PatchStrategyExactMatch {
+		processSet(av, bv, func(i int, _ any) { removed = append(removed, i) })
+	}
+	added := []int{}
+	processSet(bv, av, func(j int, _ any) { added = append(added, j) })
+	if len(removed) == 0 && len(added) == 0 {
+		return true
+	}
+	if strategy != PatchStrategyExactMatch || !collections.isFuzzySet(p) {
+		return false
+	}
+
+	score := func(i, j int) float64 { return similarity(av[i], bv[j]) }
+	pairs := pairBySimilarity(removed, added, score, collections.similarityThreshold())
+	if len(pairs) != len(removed) || len(pairs) != len(added) {
+		return false
+	}
+	// Paired elements have to become equal to their pair, as in compareArray.
+	pairedOptions := options
+	pairedOptions.exactMembers = true
+	for _, pair := range pairs {
+		if !equalValues(av[pair[0]], bv[pair[1]], makePath(p, pair[0]), strategy, collections, pairedOptions) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEqual_SameDocument_IsEqual(t *testing.T) {
+	equal, err := Equal([]byte(simpleObj), []byte(simpleObj), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+}
+
+func TestEqual_ChangedValue_IsNotEqual(t *testing.T) {
+	equal, err := Equal([]byte(`{"a":1}`), []byte(`{"a":2}`), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+}
+
+func TestEqual_ReorderedSetAndIgnoredField_IsEqual(t *testing.T) {
+	a := `{"id":"x", "s":[1, 2, 3], "t":[{"k":1, "v":1}, {"k":2, "v":2}]}`
+	b := `{"s":[3, 1, 2], "t":[{"k":2, "v":2}, {"k":1, "v":1}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}}
+	equal, err := Equal([]byte(a), []byte(b), collections, []Path{"$.id"}, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+}
+
+func TestEqual_ExtraEntityInEnsureExistsMode_IsEqual(t *testing.T) {
+	a := `{"t":[{"k":1, "v":1}, {"k":2, "v":2}]}`
+	b := `{"t":[{"k":2, "v":2}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}}
+	equal, err := Equal([]byte(a), []byte(b), collections, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	equal, err = Equal([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+}
+
+func TestEqual_PairedFuzzySetElementLosesMember_IsNotEqual(t *testing.T) {
+	a := `{"s":[{"x":1, "y":2, "z":3}]}`
+	b := `{"s":[{"x":1, "z":3}]}`
+
+	collections := Collections{FuzzySets: []Path{"$.s"}}
+	equal, err := Equal([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+}
+
+func TestEqual_InvalidDocument_ReturnsError(t *testing.T) {
+	_, err := Equal([]byte(`{`), []byte(`{}`), Collections{}, nil, PatchStrategyExactMatch)
+	assert.Error(t, err)
+}
+
+// randomEqualityDocs returns two documents drawn from a small space, the
+// second one being a shuffled and slightly changed copy of the first, so that
+// they are often equal under some collections.
+func randomEqualityDocs(r *rand.Rand) ([]byte, []byte) {
+	small := func() any {
+		switch r.Intn(5) {
+		case 0:
+			return nil
+		case 1:
+			return []any{}
+		default:
+			return float64(r.Intn(2))
+		}
+	}
+	list := func(item func(k int) any) []any {
+		items := []any{}
+		for _, k := range r.Perm(3)[:r.Intn(4)] {
+			items = append(items, item(k))
+		}
+		return items
+	}
+	entity := func(k int) map[string]any {
+		item := map[string]any{"k": float64(k)}
+		if r.Intn(2) == 0 {
+			item["v"] = small()
+		}
+		return item
+	}
+	members := map[string]func() any{
+		"a": small,
+		"o": func() any { return map[string]any{"x": small(), "y": small()} },
+		"s": func() any { return list(func(int) any { return float64(r.Intn(3)) }) },
+		"t": func() any { return list(func(k int) any { return entity(k) }) },
+		"q": func() any { return list(func(k int) any { return entity(k) }) },
+		"l": func() any { return list(func(int) any { return float64(r.Intn(2)) }) },
+		"f": func() any {
+			return list(func(k int) any {
+				element := entity(k)
+				if r.Intn(2) == 0 {
+					element["w"] = small()
+				}
+				return element
+			})
+		},
+	}
+	keys := slices.Sorted(maps.Keys(members))
+	a := map[string]any{}
+	for _, key := range keys {
+		if r.Intn(3) > 0 {
+			a[key] = members[key]()
+		}
+	}
+	b := deepCopy(a).(map[string]any)
+	for _, key := range keys {
+		switch r.Intn(8) {
+		case 0:
+			b[key] = members[key]()
+		case 1:
+			delete(b, key)
+		case 2:
+			// Drop a member of the items, which are no longer equal.
+			if items, ok := b[key].([]any); ok {
+				for _, item := range items {
+					if item, ok := item.(map[string]any); ok {
+						delete(item, "v")
+					}
+				}
+			}
+		}
+		if items, ok := b[key].([]any); ok && r.Intn(2) == 0 {
+			r.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		}
+	}
+	aJson, _ := json.Marshal([]any{a})
+	bJson, _ := json.Marshal([]any{b})
+	return aJson, bJson
+}
+
+func TestEqual_AgreesWithCreatePatch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	collections := []Collections{
+		{},
+		{
+			EntitySets:  EntitySets{"$[*].t": "k"},
+			KeyedArrays: EntitySets{"$[*].q": "k"},
+			Arrays:      []Path{"$", "$[*].l"},
+			Atomics:     []Path{"$[*].o"},
+		},
+		{
+			EntitySets:       EntitySets{"$[*].t": "k", "$[*].q": "k"},
+			Arrays:           []Path{"$"},
+			NullHandling:     NullAsDelete,
+			EmptyEquivalence: true,
+			MutableKeys:      []Path{"$[*].t"},
+			FuzzySets:        []Path{"$[*].s", "$[*].f"},
+			Defaults:         map[Path]any{"$[*].a": 0, "$[*].t[*].v": 1},
+		},
+		{
+			EntitySets:  EntitySets{"$[*].t": "k"},
+			MutableKeys: []Path{"$[*].t"},
+			FuzzySets:   []Path{"$[*].f"},
+		},
+		{
+			KeyedArrays:  EntitySets{"$[*].t": "k"},
+			Arrays:       []Path{"$[*].s"},
+			NullHandling: NullAsAbsent,
+		},
+	}
+	strategies := []PatchStrategy{PatchStrategyExactMatch, PatchStrategyEnsureExists, PatchStrategyEnsureAbsent}
+
+	equalCount := 0
+	for range 3000 {
+		a, b := randomEqualityDocs(r)
+		c := collections[r.Intn(len(collections))]
+		strategy := strategies[r.Intn(len(strategies))]
+
+		patch, err := CreatePatch(a, b, c, nil, strategy)
+		require.NoError(t, err)
+		equal, err := Equal(a, b, c, nil, strategy)
+		require.NoError(t, err)
+		assert.Equal(t, len(patch) == 0, equal, "%s vs %s (%s, %+v): %v", a, b, strategy, c, patch)
+		if equal {
+			equalCount++
+		}
+	}
+	assert.Greater(t, equalCount, 500)
+}
@@ -0,0 +1,128 @@
+package jsonpatch
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Fingerprint returns a hash of the json document 'doc', so that unchanged
+// documents can be recognised without diffing them. Ignored fields are left
+// out, sets and EntitySets are hashed regardless of their order, and members
+// that are null, empty or set to their default value are hashed like absent
+// ones where Collections says they are equivalent.
+//
+// Two documents with the same fingerprint are Equal in ExactMatch mode, and
+// generate no operations when diffed, either way round. The converse does not
+// hold: documents that are Equal may get different fingerprints, such as sets
+// with duplicates, and a different fingerprint only means the documents need
+// to be diffed.
+//
+// An error will be returned if the document is invalid.
+func Fingerprint(doc []byte, collections Collections, ignoredFields []Path) ([32]byte, error) {
+	var unmarshalled any
+	if err := json.Unmarshal(doc, &unmarshalled); err != nil {
+		return [32]byte{}, errBadJsonDoc
+	}
+	withoutIgnoredFields, err := removeIgnoredFields(unmarshalled, ignoredFields)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("error removing ignored fields from document: %w", err)
+	}
+	if collections.NullHandling == NullAsAbsent {
+		withoutIgnoredFields = removeNullFields(withoutIgnoredFields)
+	}
+	canonical, err := json.Marshal(canonicalValue(withoutIgnoredFields, "", collections))
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(canonical), nil
+}
+
+// canonicalValue returns the representative of all the values CreatePatch
+// considers equal to v at path p. Object members are sorted by json.Marshal.
+func canonicalValue(v any, p string, collections Collections) any {
+	if collections.isAtomic(p) {
+		if collections.isUnorderedAtomic(p) {
+			return sortedArrays(v)
+		}
+		return v
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(t))
+		for key, value := range t {
+			path := makePath(p, key)
+			// Values that match an absent member do not all match each other.
+			// A default value does not match null or an empty value, so only
+			// the default is left out where there is one.
+			_, hasDefault := lookupPath(collections.Defaults, toJsonPath(path))
+			switch {
+			case hasDefault && collections.isDefault(path, value):
+			case hasDefault:
+				result[key] = canonicalValue(value, path, collections)
+			case value == nil && collections.NullHandling == NullAsDelete:
+			case isEmpty(value) && collections.isEmptyEquivalent(path):
+			default:
+				result[key] = canonicalValue(value, path, collections)
+			}
+		}
+		return result
+	case []any:
+		if !collections.isArray(p) && !collections.isKeyedArray(p) && !collections.isEntitySet(p) {
+			// Sets are equal when their elements are, regardless of the order
+			// of arrays at any depth.
+			return sortedArrays(t)
+		}
+		items := make([]any, len(t))
+		for i, value := range t {
+			items[i] = canonicalValue(value, makePath(p, i), collections)
+		}
+		if collections.isEntitySet(p) {
+			return sortedItems(items)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// sortedArrays returns v with the elements of its arrays sorted, at any depth.
+func sortedArrays(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(t))
+		for key, value := range t {
+			result[key] = sortedArrays(value)
+		}
+		return result
+	case []any:
+		items := make([]any, len(t))
+		for i, value := range t {
+			items[i] = sortedArrays(value)
+		}
+		return sortedItems(items)
+	default:
+		return v
+	}
+}
+
+// sortedItems returns items sorted by their json encoding.
+func sortedItems(items []any) []any {
+	type encoded struct {
+		json  string
+		value any
+	}
+	sorted := make([]encoded, len(items))
+	for i, item := range items {
+		jsonBytes, _ := json.Marshal(item)
+		sorted[i] = encoded{string(jsonBytes), item}
+	}
+	slices.SortFunc(sorted, func(a, b encoded) int { return strings.Compare(a.json, b.json) })
+	result := make([]any, len(sorted))
+	for i, item := range sorted {
+		result[i] = item.value
+	}
+	return result
+}
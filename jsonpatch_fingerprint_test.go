@@ -0,0 +1,130 @@
+package jsonpatch
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fingerprints(t *testing.T, a, b string, collections Collections, ignored []Path) ([32]byte, [32]byte) {
+	t.Helper()
+	aHash, err := Fingerprint([]byte(a), collections, ignored)
+	require.NoError(t, err)
+	bHash, err := Fingerprint([]byte(b), collections, ignored)
+	require.NoError(t, err)
+	return aHash, bHash
+}
+
+func TestFingerprint_ReorderedSetsAndMembers_SameHash(t *testing.T) {
+	a := `{"s":[1, 2, 3], "t":[{"k":1, "v":[1, 2]}, {"k":2}], "x":{"a":1, "b":2}}`
+	b := `{"x":{"b":2, "a":1}, "t":[{"k":2}, {"v":[2, 1], "k":1}], "s":[3, 2, 1]}`
+
+	aHash, bHash := fingerprints(t, a, b, Collections{EntitySets: EntitySets{"$.t": "k"}}, nil)
+	assert.Equal(t, aHash, bHash)
+}
+
+func TestFingerprint_ReorderedArray_DifferentHash(t *testing.T) {
+	a := `{"l":[1, 2, 3]}`
+	b := `{"l":[3, 2, 1]}`
+
+	aHash, bHash := fingerprints(t, a, b, Collections{Arrays: []Path{"$.l"}}, nil)
+	assert.NotEqual(t, aHash, bHash)
+}
+
+func TestFingerprint_EqualSetsWithDuplicates_DifferentHash(t *testing.T) {
+	a := `{"s":[1, 1, 2]}`
+	b := `{"s":[1, 2]}`
+
+	equal, err := Equal([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	require.NoError(t, err)
+	assert.True(t, equal)
+	aHash, bHash := fingerprints(t, a, b, Collections{}, nil)
+	assert.NotEqual(t, aHash, bHash)
+}
+
+func TestFingerprint_IgnoredFields_SameHash(t *testing.T) {
+	a := `{"id":"x", "name":"web"}`
+	b := `{"id":"y", "name":"web"}`
+
+	aHash, bHash := fingerprints(t, a, b, Collections{}, []Path{"$.id"})
+	assert.Equal(t, aHash, bHash)
+}
+
+func TestFingerprint_EquivalentMembers_SameHash(t *testing.T) {
+	a := `{"name":"web", "tags":[], "owner":null, "timeout":30}`
+	b := `{"name":"web"}`
+
+	collections := Collections{
+		EmptyEquivalence: true,
+		NullHandling:     NullAsAbsent,
+		Defaults:         map[Path]any{"$.timeout": 30},
+	}
+	aHash, bHash := fingerprints(t, a, b, collections, nil)
+	assert.Equal(t, aHash, bHash)
+
+	aHash, bHash = fingerprints(t, a, b, Collections{}, nil)
+	assert.NotEqual(t, aHash, bHash)
+}
+
+func TestFingerprint_UnorderedAtomic_SameHash(t *testing.T) {
+	a := `{"policy":{"actions":["b", "a"], "rules":[[2, 1], [3]]}}`
+	b := `{"policy":{"actions":["a", "b"], "rules":[[3], [1, 2]]}}`
+
+	aHash, bHash := fingerprints(t, a, b, Collections{UnorderedAtomics: []Path{"$.policy"}}, nil)
+	assert.Equal(t, aHash, bHash)
+}
+
+func TestFingerprint_InvalidDocument_ReturnsError(t *testing.T) {
+	_, err := Fingerprint([]byte(`{`), Collections{}, nil)
+	assert.Error(t, err)
+}
+
+func TestFingerprint_SameHash_DocumentsAreEqual(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	collections := []Collections{
+		{},
+		{
+			EntitySets:  EntitySets{"$[*].t": "k"},
+			KeyedArrays: EntitySets{"$[*].q": "k"},
+			Arrays:      []Path{"$", "$[*].l"},
+			Atomics:     []Path{"$[*].o"},
+		},
+		{
+			EntitySets:       EntitySets{"$[*].t": "k", "$[*].q": "k"},
+			Arrays:           []Path{"$"},
+			NullHandling:     NullAsDelete,
+			EmptyEquivalence: true,
+			Defaults:         map[Path]any{"$[*].a": 0, "$[*].t[*].v": 1},
+		},
+		{
+			KeyedArrays:      EntitySets{"$[*].t": "k"},
+			Arrays:           []Path{"$", "$[*].s"},
+			UnorderedAtomics: []Path{"$[*].q"},
+			NullHandling:     NullAsAbsent,
+		},
+	}
+
+	sameCount := 0
+	for range 3000 {
+		a, b := randomEqualityDocs(r)
+		c := collections[r.Intn(len(collections))]
+
+		aHash, err := Fingerprint(a, c, nil)
+		require.NoError(t, err)
+		bHash, err := Fingerprint(b, c, nil)
+		require.NoError(t, err)
+		if aHash != bHash {
+			continue
+		}
+		sameCount++
+		equal, err := Equal(a, b, c, nil, PatchStrategyExactMatch)
+		require.NoError(t, err)
+		assert.True(t, equal, "%s vs %s (%+v)", a, b, c)
+		equal, err = Equal(b, a, c, nil, PatchStrategyExactMatch)
+		require.NoError(t, err)
+		assert.True(t, equal, "%s vs %s (%+v)", b, a, c)
+	}
+	assert.Greater(t, sameCount, 500)
+}
@@ -0,0 +1,131 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fuzzySetTestCollections = Collections{
+	FuzzySets: []Path{"$.Rules"},
+}
+
+func TestFuzzySet_OneFieldChangedInLargeSet_GeneratesNestedReplace(t *testing.T) {
+	rules := make([]any, 200)
+	for i := range rules {
+		rules[i] = map[string]any{"Cidr": fmt.Sprintf("10.0.%d.0/24", i), "Port": float64(443), "Protocol": "tcp"}
+	}
+	a, err := json.Marshal(map[string]any{"Rules": rules})
+	require.NoError(t, err)
+	rules[117] = map[string]any{"Cidr": "10.0.117.0/24", "Port": float64(8443), "Protocol": "tcp"}
+	b, err := json.Marshal(map[string]any{"Rules": rules})
+	require.NoError(t, err)
+
+	patch, err := CreatePatch(a, b, fuzzySetTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/Rules/117/Port", float64(8443))}, patch)
+}
+
+func TestFuzzySet_IndexesAccountForRemoves(t *testing.T) {
+	a := `{"Rules":[{"Cidr":"a", "Port":1}, {"Cidr":"b", "Port":2}, {"Cidr":"c", "Port":3}]}`
+	b := `{"Rules":[{"Cidr":"c", "Port":4}, {"Cidr":"b", "Port":2}, {"Other":true}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), fuzzySetTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/Rules/0", nil),
+		NewPatch("replace", "/Rules/1/Port", float64(4)),
+		NewPatch("add", "/Rules/2", map[string]any{"Other": true}),
+	}, patch)
+}
+
+func TestFuzzySet_PairedElementWithExtraMember_RemovesMember(t *testing.T) {
+	a := `{"Rules":[{"Cidr":"a", "Port":1, "Protocol":"tcp", "Stale":true}]}`
+	b := `{"Rules":[{"Cidr":"a", "Port":2, "Protocol":"tcp"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), fuzzySetTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/Rules/0/Port", float64(2)),
+		NewPatch("remove", "/Rules/0/Stale", nil),
+	}, patch)
+}
+
+func TestFuzzySet_NotConfigured_GeneratesRemoveAndAdd(t *testing.T) {
+	a := `{"Rules":[{"Cidr":"a", "Port":1}]}`
+	b := `{"Rules":[{"Cidr":"a", "Port":2}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/Rules/0", nil),
+		NewPatch("add", "/Rules/0", map[string]any{"Cidr": "a", "Port": float64(2)}),
+	}, patch)
+}
+
+func TestFuzzySet_ScalarElements_NeverPaired(t *testing.T) {
+	a := `{"Rules":["a", "b"]}`
+	b := `{"Rules":["a", "c"]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), fuzzySetTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/Rules/1", nil),
+		NewPatch("add", "/Rules/1", "c"),
+	}, patch)
+}
+
+func TestAssign_MaximisesTotalWeight(t *testing.T) {
+	// Pairing the most similar items first would pick 0-0 and leave 1-1 worth nothing.
+	weights := [][]float64{
+		{0.9, 0.8},
+		{0.85, 0},
+	}
+	assert.Equal(t, []int{1, 0}, assign(weights))
+
+	// More rows than columns leaves a row unassigned.
+	weights = [][]float64{
+		{0.1},
+		{0.7},
+		{0.3},
+	}
+	assert.Equal(t, []int{-1, 0, -1}, assign(weights))
+}
+
+func TestFuzzySet_PatchesApplyAndConverge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomDoc := func() []byte {
+		// Sets hold distinct elements, so Cidr is unique within a document.
+		rules := []any{}
+		for _, cidr := range r.Perm(6)[:r.Intn(6)] {
+			ports := []any{}
+			for _, port := range r.Perm(3)[:r.Intn(4)] {
+				ports = append(ports, float64(port))
+			}
+			rule := map[string]any{
+				"Cidr":  float64(cidr),
+				"Port":  float64(r.Intn(3)),
+				"Ports": ports,
+			}
+			// Elements do not all have the same members.
+			if r.Intn(2) == 0 {
+				rule["Extra"] = map[string]any{"Port": float64(r.Intn(2))}
+			}
+			if r.Intn(3) == 0 {
+				delete(rule, "Port")
+			}
+			rules = append(rules, rule)
+		}
+		doc, err := json.Marshal(map[string]any{"Rules": rules})
+		require.NoError(t, err)
+		return doc
+	}
+
+	for range 500 {
+		assertPatchConverges(t, randomDoc(), randomDoc(), fuzzySetTestCollections, PatchStrategyExactMatch)
+	}
+}
@@ -0,0 +1,58 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoredFields_EscapedMember_Ignored(t *testing.T) {
+	a := `{"metadata":{"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{}", "team":"a"}}}`
+	b := `{"metadata":{"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"x\":1}", "team":"a"}}}`
+
+	ignored := []Path{`$.metadata.annotations.kubectl\.kubernetes\.io/last-applied-configuration`}
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, ignored, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{}, patch)
+}
+
+func TestIgnoredFields_PathForms(t *testing.T) {
+	doc := `{"a":{"b":1, "c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`
+	cases := []struct {
+		path     Path
+		expected string
+	}{
+		{"$.a.b", `{"a":{"c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{"a.b", `{"a":{"c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{`$.a.c\.d`, `{"a":{"b":1, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{`$.a.\*`, `{"a":{"b":1, "c.d":2, "e":[1, 2, 3]}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{"$.a.e.0", `{"a":{"b":1, "c.d":2, "e":[2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{"$.n.0.p", `{"a":{"b":1, "c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{}}}`},
+		{"$.l[*].b", `{"a":{"b":1, "c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"c":{"d":[{"e":1, "f":2}]}}, {}], "n":{"0":{"p":1}}}`},
+		{"$.l[*].c", `{"a":{"b":1, "c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{"$.l[*].c.d[*].e", `{"a":{"b":1, "c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"f":2}]}}, {"b":2}], "n":{"0":{"p":1}}}`},
+		{"$.n.*.p", `{"a":{"b":1, "c.d":2, "e":[1, 2, 3], "*":4}, "l":[{"b":1, "c":{"d":[{"e":1, "f":2}]}}, {"b":2}], "n":{"0":{}}}`},
+		{"$.a.*.x", doc},
+		{"$.missing.x", doc},
+	}
+	for _, c := range cases {
+		t.Run(string(c.path), func(t *testing.T) {
+			var data any
+			require.NoError(t, json.Unmarshal([]byte(doc), &data))
+			result, err := removeIgnoredFields(data, []Path{c.path})
+			require.NoError(t, err)
+			actual, err := json.Marshal(result)
+			require.NoError(t, err)
+			assert.JSONEq(t, c.expected, string(actual))
+		})
+	}
+}
+
+func TestIgnoredFields_InvalidPath_ReturnsError(t *testing.T) {
+	for _, path := range []Path{"$", "$.l[*]", "$.a.*", "$.a[0]", "$..b"} {
+		_, err := CreatePatch([]byte(`{"a":{}}`), []byte(`{"a":{}}`), Collections{}, []Path{path}, PatchStrategyExactMatch)
+		assert.Error(t, err, path)
+	}
+}
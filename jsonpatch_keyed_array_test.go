@@ -0,0 +1,103 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var keyedArrayTestCollections = Collections{
+	KeyedArrays: EntitySets{
+		Path("$.stages"):          Key("name"),
+		Path("$.stages[*].steps"): Key("id"),
+	},
+}
+
+func TestKeyedArray_SameOrder_NestedChange_GeneratesReplace(t *testing.T) {
+	a := `{"stages":[{"name":"build", "image":"go:1.22"}, {"name":"test", "image":"go:1.22"}]}`
+	b := `{"stages":[{"name":"build", "image":"go:1.23"}, {"name":"test", "image":"go:1.22"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), keyedArrayTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/stages/0/image", "go:1.23")}, patch)
+}
+
+func TestKeyedArray_Reordered_GeneratesMove(t *testing.T) {
+	a := `{"stages":[{"name":"a"}, {"name":"b"}, {"name":"c"}, {"name":"d"}]}`
+	b := `{"stages":[{"name":"b"}, {"name":"c"}, {"name":"d"}, {"name":"a"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), keyedArrayTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewMovePatch("/stages/0", "/stages/3")}, patch)
+}
+
+func TestKeyedArray_InsertAndRemove_GeneratesPositionalOps(t *testing.T) {
+	a := `{"stages":[{"name":"build"}, {"name":"lint"}, {"name":"deploy"}]}`
+	b := `{"stages":[{"name":"build"}, {"name":"test"}, {"name":"deploy", "env":"prod"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), keyedArrayTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/stages/1", nil),
+		NewPatch("add", "/stages/1", map[string]any{"name": "test"}),
+		NewPatch("add", "/stages/2/env", "prod"),
+	}, patch)
+}
+
+func TestKeyedArray_EnsureExists_KeepsUnmatchedItems(t *testing.T) {
+	a := `{"stages":[{"name":"a"}, {"name":"x"}, {"name":"b"}]}`
+	b := `{"stages":[{"name":"b"}, {"name":"a"}, {"name":"c"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), keyedArrayTestCollections, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewMovePatch("/stages/0", "/stages/2"),
+		NewPatch("add", "/stages/3", map[string]any{"name": "c"}),
+	}, patch)
+}
+
+func TestKeyedArray_Equal_NoPatch(t *testing.T) {
+	a := `{"stages":[{"name":"a", "steps":[{"id":1}, {"id":2}]}, {"name":"b"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(a), keyedArrayTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestKeyedArray_MoveMarshalsFrom(t *testing.T) {
+	op := NewMovePatch("/stages/0", "/stages/3")
+	b, err := op.MarshalJson()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"op":"move", "from":"/stages/0", "path":"/stages/3"}`, string(b))
+	assert.JSONEq(t, `{"op":"move", "from":"/stages/0", "path":"/stages/3"}`, op.Json())
+}
+
+func randomKeyedArrayDoc(r *rand.Rand) map[string]any {
+	stages := []any{}
+	for _, name := range r.Perm(6)[:r.Intn(7)] {
+		steps := []any{}
+		for _, id := range r.Perm(4)[:r.Intn(5)] {
+			steps = append(steps, map[string]any{"id": float64(id), "run": float64(r.Intn(2))})
+		}
+		stages = append(stages, map[string]any{"name": float64(name), "image": float64(r.Intn(3)), "steps": steps})
+	}
+	return map[string]any{"stages": stages}
+}
+
+func TestKeyedArray_PatchesApplyAndConverge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, strategy := range []PatchStrategy{PatchStrategyExactMatch, PatchStrategyEnsureExists} {
+		for range 500 {
+			a, err := json.Marshal(randomKeyedArrayDoc(r))
+			require.NoError(t, err)
+			b, err := json.Marshal(randomKeyedArrayDoc(r))
+			require.NoError(t, err)
+
+			assertPatchConverges(t, a, b, keyedArrayTestCollections, strategy)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPatchTooLarge is matched by the error CreatePatch returns when the
+// patch exceeds the limits of WithMaxOperations or WithMaxPatchBytes.
+// Callers should fall back to sending the whole document.
+var ErrPatchTooLarge = errors.New("patch exceeds limits")
+
+// PatchLimitError reports the size of a patch that exceeds its limits.
+type PatchLimitError struct {
+	Operations    int
+	MaxOperations int
+	Bytes         int
+	MaxPatchBytes int
+}
+
+func (e *PatchLimitError) Error() string {
+	return fmt.Sprintf("%s: %d operations (max %d), %d bytes (max %d)",
+		ErrPatchTooLarge, e.Operations, e.MaxOperations, e.Bytes, e.MaxPatchBytes)
+}
+
+func (e *PatchLimitError) Unwrap() error {
+	return ErrPatchTooLarge
+}
+
+// patchSize returns the size of the patch serialized as a json array.
+func patchSize(patch []JsonPatchOperation) (int, error) {
+	cost, err := operationsCost(patch)
+	if err != nil {
+		return 0, err
+	}
+	// Brackets and the commas between operations.
+	return cost + len(patch) + 1, nil
+}
+
+// enforceLimits returns patch if it is within the limits of options.
+// Otherwise it returns a replace of the whole document, if allowed and within
+// the limits itself, or a *PatchLimitError. doc is the original document the
+// patch applies to. It is not modified.
+func enforceLimits(doc any, patch []JsonPatchOperation, options patchOptions) ([]JsonPatchOperation, error) {
+	if options.maxOperations <= 0 && options.maxPatchBytes <= 0 {
+		return patch, nil
+	}
+	err := checkLimits(patch, options)
+	if err == nil {
+		return patch, nil
+	}
+	if !options.replaceOverLimits {
+		return nil, err
+	}
+	value, err := applyPatch(deepCopy(doc), patch)
+	if err != nil {
+		return nil, fmt.Errorf("error applying patch for whole document replace: %w", err)
+	}
+	replace := []JsonPatchOperation{NewPatch("replace", "", value)}
+	if err := checkLimits(replace, options); err != nil {
+		return nil, err
+	}
+	return replace, nil
+}
+
+func checkLimits(patch []JsonPatchOperation, options patchOptions) error {
+	size, err := patchSize(patch)
+	if err != nil {
+		return err
+	}
+	if (options.maxOperations > 0 && len(patch) > options.maxOperations) ||
+		(options.maxPatchBytes > 0 && size > options.maxPatchBytes) {
+		return &PatchLimitError{
+			Operations:    len(patch),
+			MaxOperations: options.maxOperations,
+			Bytes:         size,
+			MaxPatchBytes: options.maxPatchBytes,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	limitsBase    = `{"id":"x", "a":1, "b":2, "c":3}`
+	limitsUpdated = `{"a":4, "b":5, "c":6}`
+)
+
+func TestLimits_WithinLimits_ReturnsPatch(t *testing.T) {
+	options := []PatchOption{WithMaxOperations(3), WithMaxPatchBytes(200)}
+	patch, err := CreatePatch([]byte(limitsBase), []byte(limitsUpdated), Collections{}, nil, PatchStrategyExactMatch, options...)
+	assert.NoError(t, err)
+	assert.Len(t, patch, 3)
+}
+
+func TestLimits_TooManyOperations_ReturnsPatchLimitError(t *testing.T) {
+	options := []PatchOption{WithMaxOperations(2)}
+	patch, err := CreatePatch([]byte(limitsBase), []byte(limitsUpdated), Collections{}, nil, PatchStrategyExactMatch, options...)
+	assert.Nil(t, patch)
+	assert.ErrorIs(t, err, ErrPatchTooLarge)
+
+	var limitErr *PatchLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, 3, limitErr.Operations)
+	assert.Equal(t, 2, limitErr.MaxOperations)
+}
+
+func TestLimits_TooManyBytes_ReturnsPatchLimitError(t *testing.T) {
+	options := []PatchOption{WithMaxPatchBytes(50)}
+	_, err := CreatePatch([]byte(limitsBase), []byte(limitsUpdated), Collections{}, nil, PatchStrategyExactMatch, options...)
+
+	var limitErr *PatchLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, len(`[{"op":"replace","path":"/a","value":4},{"op":"replace","path":"/b","value":5},{"op":"replace","path":"/c","value":6}]`), limitErr.Bytes)
+}
+
+func TestLimits_ReplaceOverLimits_GeneratesRootReplace(t *testing.T) {
+	options := []PatchOption{WithMaxOperations(2), WithReplaceOverLimits()}
+	ignored := []Path{"$.id"}
+	patch, err := CreatePatch([]byte(limitsBase), []byte(limitsUpdated), Collections{}, ignored, PatchStrategyExactMatch, options...)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "", map[string]any{"id": "x", "a": float64(4), "b": float64(5), "c": float64(6)}),
+	}, patch)
+}
+
+func TestLimits_RootReplaceOverLimits_ReturnsPatchLimitError(t *testing.T) {
+	options := []PatchOption{WithMaxPatchBytes(50), WithReplaceOverLimits()}
+	_, err := CreatePatch([]byte(limitsBase), []byte(limitsUpdated), Collections{}, nil, PatchStrategyExactMatch, options...)
+	assert.ErrorIs(t, err, ErrPatchTooLarge)
+}
@@ -0,0 +1,255 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// ErrNullNotRepresentable is returned when a JSON Merge Patch would need to
+// set a member to null, which RFC 7396 reads as removing the member instead.
+var ErrNullNotRepresentable = errors.New("null value cannot be represented in a merge patch")
+
+// CreateMergePatch creates a JSON Merge Patch as specified in RFC 7396.
+//
+// 'a' is original, 'b' is the modified document. Both are to be given as json
+// encoded content. The changes are the ones CreatePatch generates for the same
+// collections, ignored fields and strategy. Merge patches cannot address array
+// elements, so arrays that change are replaced as a whole with their patched
+// value, whatever kind of collection they are.
+//
+// An error will be returned if any of the two documents are invalid, or if
+// the patch would need to set a member to null.
+func CreateMergePatch(a, b []byte, collections Collections, ignoredFields []Path, strategy PatchStrategy) ([]byte, error) {
+	aUnmarshalled, aWithoutIgnoredFields, bWithoutIgnoredFields, err := prepareDocuments(a, b, collections, ignoredFields)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := handleValues(aWithoutIgnoredFields, bWithoutIgnoredFields, "", []JsonPatchOperation{}, strategy, collections, patchOptions{})
+	if err != nil {
+		return nil, err
+	}
+	patched, err := applyPatch(deepCopy(aUnmarshalled), patch)
+	if err != nil {
+		return nil, fmt.Errorf("error applying patch to original document: %w", err)
+	}
+	mergePatch, err := mergeDiff(aUnmarshalled, patched, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch)
+}
+
+// mergeDiff returns the merge patch that turns av into bv.
+func mergeDiff(av, bv any, p string) (any, error) {
+	at, aIsObject := av.(map[string]any)
+	bt, bIsObject := bv.(map[string]any)
+	if !aIsObject || !bIsObject {
+		return bv, checkNoNullMembers(bv, p)
+	}
+	result := map[string]any{}
+	for key, bValue := range bt {
+		path := makePath(p, key)
+		aValue, ok := at[key]
+		switch {
+		case bValue == nil && (!ok || aValue != nil):
+			return nil, fmt.Errorf("%w: %s", ErrNullNotRepresentable, path)
+		case !ok:
+			if err := checkNoNullMembers(bValue, path); err != nil {
+				return nil, err
+			}
+			result[key] = bValue
+		case reflect.DeepEqual(aValue, bValue):
+			// Unlike matchesValue, null members do not match absent ones here.
+		default:
+			if _, isObject := aValue.(map[string]any); !isObject {
+				// Anything replacing a non-object is merged into an empty object.
+				aValue = map[string]any{}
+			}
+			changes, err := mergeDiff(aValue, bValue, path)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = changes
+		}
+	}
+	for key := range at {
+		if _, ok := bt[key]; !ok {
+			result[key] = nil
+		}
+	}
+	return result, nil
+}
+
+// checkNoNullMembers returns an error if v is an object with a null member
+// at any depth, as merging it would remove the member instead.
+func checkNoNullMembers(v any, p string) error {
+	t, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	for key, value := range t {
+		path := makePath(p, key)
+		if value == nil {
+			return fmt.Errorf("%w: %s", ErrNullNotRepresentable, path)
+		}
+		if err := checkNoNullMembers(value, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyMergePatch applies the JSON Merge Patch 'patch' to the json document
+// 'doc' as specified in RFC 7396, and returns the resulting json document.
+// Null members of the patch remove the member from the document.
+//
+// An error will be returned if the document or the patch is invalid.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	var docUnmarshalled any
+	var patchUnmarshalled any
+
+	err := json.Unmarshal(doc, &docUnmarshalled)
+	if err != nil {
+		return nil, errBadJsonDoc
+	}
+	err = json.Unmarshal(patch, &patchUnmarshalled)
+	if err != nil {
+		return nil, errBadJsonDoc
+	}
+	return json.Marshal(mergeValues(docUnmarshalled, patchUnmarshalled))
+}
+
+// mergeValues returns target with the merge patch applied. target is
+// modified in place.
+func mergeValues(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValues(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// ToMergePatch converts a JSON Patch to a JSON Merge Patch that turns the json
+// document 'base' into the same document. Merge patches only set and remove
+// object members, so the operations that address array elements, move or copy
+// values, or test them cannot be represented as such. Their effect is kept by
+// replacing the enclosing member as a whole, and they are returned alongside
+// the merge patch so callers can tell whether the conversion is lossless.
+//
+// An error will be returned if the document is invalid, if the patch does not
+// apply to it, or if the patch sets a member to null.
+func ToMergePatch(patch []JsonPatchOperation, base []byte) ([]byte, []JsonPatchOperation, error) {
+	var doc any
+	if err := json.Unmarshal(base, &doc); err != nil {
+		return nil, nil, errBadJsonDoc
+	}
+	unrepresentable := []JsonPatchOperation{}
+	patched := deepCopy(doc)
+	for _, op := range patch {
+		if !representableInMergePatch(patched, op) {
+			unrepresentable = append(unrepresentable, op)
+		}
+		var err error
+		patched, err = applyPatch(patched, []JsonPatchOperation{op})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	mergePatch, err := mergeDiff(doc, patched, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	mergePatchJson, err := json.Marshal(mergePatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mergePatchJson, unrepresentable, nil
+}
+
+// representableInMergePatch reports whether op only adds, replaces or removes
+// an object member of doc.
+func representableInMergePatch(doc any, op JsonPatchOperation) bool {
+	switch op.Operation {
+	case "add", "replace", "remove":
+	default:
+		return false
+	}
+	tokens, err := pointerTokens(op.Path)
+	if err != nil {
+		return false
+	}
+	node := doc
+	for _, token := range tokens {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		node = object[token]
+	}
+	return true
+}
+
+// FromMergePatch converts the JSON Merge Patch 'mergePatch' to the JSON Patch
+// operations that turn the json document 'base' into the same document.
+// Members are visited in sorted order so the generated patch is deterministic.
+//
+// An error will be returned if the document or the merge patch is invalid.
+func FromMergePatch(mergePatch, base []byte) ([]JsonPatchOperation, error) {
+	var doc any
+	var mergePatchUnmarshalled any
+
+	err := json.Unmarshal(base, &doc)
+	if err != nil {
+		return nil, errBadJsonDoc
+	}
+	err = json.Unmarshal(mergePatch, &mergePatchUnmarshalled)
+	if err != nil {
+		return nil, errBadJsonDoc
+	}
+	return mergeOperations(doc, mergePatchUnmarshalled, "", []JsonPatchOperation{}), nil
+}
+
+// mergeOperations appends the operations that apply the merge patch to the
+// value at path p, which is target.
+func mergeOperations(target, mergePatch any, p string, patch []JsonPatchOperation) []JsonPatchOperation {
+	patchObject, isObject := mergePatch.(map[string]any)
+	targetObject, targetIsObject := target.(map[string]any)
+	if !isObject || !targetIsObject {
+		value := mergeValues(nil, mergePatch)
+		if reflect.DeepEqual(target, value) {
+			return patch
+		}
+		return append(patch, NewPatch("replace", p, value))
+	}
+	for _, key := range slices.Sorted(maps.Keys(patchObject)) {
+		value := patchObject[key]
+		path := makePath(p, key)
+		current, ok := targetObject[key]
+		switch {
+		case value == nil:
+			if ok {
+				patch = append(patch, NewPatch("remove", path, nil))
+			}
+		case !ok:
+			patch = append(patch, NewPatch("add", path, mergeValues(nil, value)))
+		default:
+			patch = mergeOperations(current, value, path, patch)
+		}
+	}
+	return patch
+}
@@ -0,0 +1,237 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMergePatch_ChangedMembers_OnlyChangesInPatch(t *testing.T) {
+	a := `{"name":"web", "spec":{"replicas":1, "image":"web:1"}, "labels":{"app":"web"}}`
+	b := `{"name":"web", "spec":{"replicas":2, "image":"web:1", "port":80}, "labels":{"app":"web"}}`
+
+	patch, err := CreateMergePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"replicas":2, "port":80}}`, string(patch))
+}
+
+func TestCreateMergePatch_NoChanges_EmptyPatch(t *testing.T) {
+	patch, err := CreateMergePatch([]byte(`{"s":[1, 2]}`), []byte(`{"s":[2, 1]}`), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(patch))
+}
+
+func TestCreateMergePatch_RemovedMembers_SetToNull(t *testing.T) {
+	a := `{"a":1, "b":2, "t":[{"k":1}]}`
+	b := `{"a":1, "b":null}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}, NullHandling: NullAsDelete}
+	patch, err := CreateMergePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"b":null, "t":null}`, string(patch))
+}
+
+func TestCreateMergePatch_ReplacedValueWithoutNullMember_SetToNull(t *testing.T) {
+	a := `{"a":{"x":{"b":null}, "y":1}}`
+	b := `{"a":{"x":{}, "y":2}}`
+
+	collections := Collections{Atomics: []Path{"$.a"}}
+	patch, err := CreateMergePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":{"x":{"b":null}, "y":2}}`, string(patch))
+}
+
+func TestCreateMergePatch_ChangedEntitySet_ReplacedAsAWhole(t *testing.T) {
+	a := `{"t":[{"k":1, "v":1, "id":"x"}, {"k":2, "v":2, "id":"y"}]}`
+	b := `{"t":[{"k":2, "v":3}]}`
+
+	collections := Collections{EntitySets: EntitySets{"$.t": "k"}}
+	ignored := []Path{"$.t[*].id"}
+	patch, err := CreateMergePatch([]byte(a), []byte(b), collections, ignored, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"t":[{"k":1, "v":1, "id":"x"}, {"k":2, "v":3, "id":"y"}]}`, string(patch))
+}
+
+func TestCreateMergePatch_ObjectReplacingScalar_MergedIntoEmptyObject(t *testing.T) {
+	patch, err := CreateMergePatch([]byte(`{"a":1}`), []byte(`{"a":{"b":2}}`), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":{"b":2}}`, string(patch))
+}
+
+func TestCreateMergePatch_NullValue_ReturnsError(t *testing.T) {
+	cases := map[string]struct {
+		a string
+		b string
+	}{
+		"value to null":          {`{"a":1}`, `{"a":null}`},
+		"absent to null":         {`{}`, `{"a":null}`},
+		"null in added object":   {`{}`, `{"a":{"b":null}}`},
+		"null in changed object": {`{"a":1}`, `{"a":{"b":null}}`},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := CreateMergePatch([]byte(c.a), []byte(c.b), Collections{}, nil, PatchStrategyExactMatch)
+			assert.ErrorIs(t, err, ErrNullNotRepresentable)
+		})
+	}
+}
+
+func TestCreateMergePatch_NullInArray_IsLiteral(t *testing.T) {
+	collections := Collections{Arrays: []Path{"$.l"}}
+	patch, err := CreateMergePatch([]byte(`{"l":[1, 2]}`), []byte(`{"l":[1, null]}`), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"l":[1, null]}`, string(patch))
+}
+
+func TestApplyMergePatch_RFC7396Examples(t *testing.T) {
+	// Test cases from Appendix A of RFC 7396.
+	cases := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b", "b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b", "b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d", "c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null, "a":1}`},
+		{`[1,2]`, `{"a":"b", "c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		result, err := ApplyMergePatch([]byte(c.doc), []byte(c.patch))
+		assert.NoError(t, err)
+		assert.JSONEq(t, c.expected, string(result), "%s merged with %s", c.doc, c.patch)
+	}
+}
+
+func TestApplyMergePatch_InvalidDocument_ReturnsError(t *testing.T) {
+	_, err := ApplyMergePatch([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+	_, err = ApplyMergePatch([]byte(`{}`), []byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestCreateMergePatch_AppliesLikeJsonPatch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	collections := Collections{
+		EntitySets:  EntitySets{"$[*].t": "k"},
+		KeyedArrays: EntitySets{"$[*].q": "k"},
+		Arrays:      []Path{"$[*].l"},
+	}
+
+	merges := 0
+	for range 1000 {
+		a, b := randomEqualityDocs(r)
+		// Merge patches apply to objects only, so unwrap the documents.
+		a, b = a[1:len(a)-1], b[1:len(b)-1]
+		for _, strategy := range []PatchStrategy{PatchStrategyExactMatch, PatchStrategyEnsureExists} {
+			mergePatch, err := CreateMergePatch(a, b, collections, nil, strategy)
+			if errors.Is(err, ErrNullNotRepresentable) {
+				continue
+			}
+			require.NoError(t, err)
+			merged, err := ApplyMergePatch(a, mergePatch)
+			require.NoError(t, err)
+			merges++
+
+			_, patched := applyCreatedPatch(t, a, b, collections, strategy)
+			assert.JSONEq(t, string(patched), string(merged), "%s merged with %s", a, mergePatch)
+		}
+	}
+	assert.Greater(t, merges, 500)
+}
+
+func TestToMergePatch_ObjectMembers_Lossless(t *testing.T) {
+	base := `{"a":1, "b":{"c":2}, "d":3}`
+	patch := []JsonPatchOperation{
+		NewPatch("replace", "/a", float64(4)),
+		NewPatch("add", "/b/e", float64(5)),
+		NewPatch("remove", "/d", nil),
+	}
+
+	mergePatch, unrepresentable, err := ToMergePatch(patch, []byte(base))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":4, "b":{"e":5}, "d":null}`, string(mergePatch))
+	assert.Empty(t, unrepresentable)
+}
+
+func TestToMergePatch_ArrayAndMoveOperations_ReportedAndReplacedAsAWhole(t *testing.T) {
+	base := `{"l":[1, 2], "a":1}`
+	patch := []JsonPatchOperation{
+		NewPatch("add", "/l/1", float64(3)),
+		NewMovePatch("/a", "/b"),
+		NewPatch("test", "/b", float64(1)),
+	}
+
+	mergePatch, unrepresentable, err := ToMergePatch(patch, []byte(base))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"l":[1, 3, 2], "a":null, "b":1}`, string(mergePatch))
+	assert.Equal(t, patch, unrepresentable)
+}
+
+func TestToMergePatch_NullValue_ReturnsError(t *testing.T) {
+	_, _, err := ToMergePatch([]JsonPatchOperation{NewPatch("add", "/a", nil)}, []byte(`{}`))
+	assert.ErrorIs(t, err, ErrNullNotRepresentable)
+}
+
+func TestToMergePatch_PatchDoesNotApply_ReturnsError(t *testing.T) {
+	_, _, err := ToMergePatch([]JsonPatchOperation{NewPatch("remove", "/a", nil)}, []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestFromMergePatch_GeneratesOperations(t *testing.T) {
+	base := `{"a":1, "b":{"c":2, "d":3}, "e":[1], "f":"x"}`
+	mergePatch := `{"a":1, "b":{"c":null, "g":{"h":null, "i":4}}, "e":{"j":null}, "f":null, "k":null}`
+
+	patch, err := FromMergePatch([]byte(mergePatch), []byte(base))
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/b/c", nil),
+		NewPatch("add", "/b/g", map[string]any{"i": float64(4)}),
+		NewPatch("replace", "/e", map[string]any{}),
+		NewPatch("remove", "/f", nil),
+	}, patch)
+}
+
+func TestFromMergePatch_AppliesLikeMergePatch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for range 1000 {
+		a, b := randomEqualityDocs(r)
+		a, b = a[1:len(a)-1], b[1:len(b)-1]
+		// Any document is a merge patch; nulls in b remove members.
+		merged, err := ApplyMergePatch(a, b)
+		require.NoError(t, err)
+
+		patch, err := FromMergePatch(b, a)
+		require.NoError(t, err)
+		var doc any
+		require.NoError(t, json.Unmarshal(a, &doc))
+		doc, err = applyPatch(doc, patch)
+		require.NoError(t, err)
+		patched, err := json.Marshal(doc)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(merged), string(patched), "%s merged with %s", a, b)
+
+		// And converting back gives an equivalent merge patch.
+		mergePatch, unrepresentable, err := ToMergePatch(patch, a)
+		require.NoError(t, err)
+		assert.Empty(t, unrepresentable)
+		remerged, err := ApplyMergePatch(a, mergePatch)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(merged), string(remerged))
+	}
+}
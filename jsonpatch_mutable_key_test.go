@@ -0,0 +1,127 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mutableKeyTestCollections = Collections{
+	EntitySets: EntitySets{
+		Path("$.Rules"):             Key("Name"),
+		Path("$.Rules[*].Matchers"): Key("Field"),
+	},
+	MutableKeys: []Path{"$.Rules"},
+}
+
+func TestMutableKey_RenamedItem_GeneratesKeyReplace(t *testing.T) {
+	a := `{"Rules":[{"Name":"a", "Priority":1, "Action":"forward"}, {"Name":"b", "Priority":2, "Action":"deny"}]}`
+	b := `{"Rules":[{"Name":"a", "Priority":1, "Action":"forward"}, {"Name":"c", "Priority":2, "Action":"deny"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), mutableKeyTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/Rules/1/Name", "c")}, patch)
+}
+
+func TestMutableKey_RenamedAndModifiedItem_GeneratesNestedOps(t *testing.T) {
+	a := `{"Rules":[{"Name":"old", "Priority":1, "Action":"forward", "Matchers":[{"Field":"path", "Value":"/a"}]}]}`
+	b := `{"Rules":[{"Name":"new", "Priority":1, "Action":"redirect", "Matchers":[{"Field":"path", "Value":"/a"}]}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), mutableKeyTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/Rules/0/Action", "redirect"),
+		NewPatch("replace", "/Rules/0/Name", "new"),
+	}, patch)
+}
+
+func TestMutableKey_RenamedItemWithExtraMember_RemovesMember(t *testing.T) {
+	a := `{"Rules":[{"Name":"old", "Priority":1, "Action":"forward", "Port":80, "Stale":true}]}`
+	b := `{"Rules":[{"Name":"new", "Priority":1, "Action":"forward", "Port":80}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), mutableKeyTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/Rules/0/Name", "new"),
+		NewPatch("remove", "/Rules/0/Stale", nil),
+	}, patch)
+}
+
+func TestMutableKey_DissimilarItems_GenerateRemoveAndAdd(t *testing.T) {
+	a := `{"Rules":[{"Name":"old", "Priority":1, "Action":"forward"}]}`
+	b := `{"Rules":[{"Name":"new", "Priority":2, "Action":"deny"}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), mutableKeyTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/Rules/0", nil),
+		NewPatch("add", "/Rules/0", map[string]any{"Name": "new", "Priority": float64(2), "Action": "deny"}),
+	}, patch)
+}
+
+func TestMutableKey_ThresholdIsConfigurable(t *testing.T) {
+	a := `{"Rules":[{"Name":"old", "Priority":1, "Action":"forward", "Port":80}]}`
+	b := `{"Rules":[{"Name":"new", "Priority":2, "Action":"forward", "Port":80}]}`
+
+	collections := mutableKeyTestCollections
+	collections.SimilarityThreshold = 0.9
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Len(t, patch, 2)
+	assert.Equal(t, "remove", patch[0].Operation)
+
+	collections.SimilarityThreshold = 0.6
+	patch, err = CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/Rules/0/Name", "new"),
+		NewPatch("replace", "/Rules/0/Priority", float64(2)),
+	}, patch)
+}
+
+func TestMutableKey_NotConfigured_GeneratesRemoveAndAdd(t *testing.T) {
+	a := `{"Rules":[{"Name":"b", "Priority":2}]}`
+	b := `{"Rules":[{"Name":"c", "Priority":2}]}`
+
+	collections := Collections{EntitySets: mutableKeyTestCollections.EntitySets}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Len(t, patch, 2)
+	assert.Equal(t, "remove", patch[0].Operation)
+	assert.Equal(t, "add", patch[1].Operation)
+}
+
+func TestMutableKey_InEnsureExistsMode_KeepsOldItem(t *testing.T) {
+	a := `{"Rules":[{"Name":"b", "Priority":2}]}`
+	b := `{"Rules":[{"Name":"c", "Priority":2}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), mutableKeyTestCollections, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("add", "/Rules/1", map[string]any{"Name": "c", "Priority": float64(2)}),
+	}, patch)
+}
+
+func TestMutableKey_PatchesApplyAndConverge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomDoc := func() []byte {
+		rules := []any{}
+		for _, name := range r.Perm(8)[:r.Intn(6)] {
+			rules = append(rules, map[string]any{
+				"Name":     float64(name),
+				"Priority": float64(r.Intn(3)),
+				"Action":   float64(r.Intn(2)),
+			})
+		}
+		doc, err := json.Marshal(map[string]any{"Rules": rules})
+		require.NoError(t, err)
+		return doc
+	}
+
+	for range 500 {
+		assertPatchConverges(t, randomDoc(), randomDoc(), mutableKeyTestCollections, PatchStrategyExactMatch)
+	}
+}
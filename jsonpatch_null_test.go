@@ -0,0 +1,146 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNullAsValue_ValueToNull_GeneratesReplace(t *testing.T) {
+	a := `{"a":1, "b":"x"}`
+	b := `{"a":1, "b":null}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{NullHandling: NullAsValue}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/b", nil)}, patch)
+}
+
+func TestNullAsValue_AbsentToNull_GeneratesAdd(t *testing.T) {
+	a := `{"a":1}`
+	b := `{"a":1, "b":null}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/b", nil)}, patch)
+}
+
+func TestNullAsValue_NullInSet_NoPatch(t *testing.T) {
+	a := `{"s":[{"k":null}, 1]}`
+	b := `{"s":[1, {"k":null}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestNullAsValue_AbsentToNullInArrayItem_GeneratesAdd(t *testing.T) {
+	a := `{"l":[{"k":3}, {"k":2}]}`
+	b := `{"l":[{"c":null, "k":3}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{Arrays: []Path{"$.l"}}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("add", "/l/0/c", nil),
+		NewPatch("remove", "/l/1", nil),
+	}, patch)
+}
+
+func TestNullAsValue_AbsentToNullInSet_GeneratesPatch(t *testing.T) {
+	a := `{"s":[{"k":null}]}`
+	b := `{"s":[{}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/s/0", nil),
+		NewPatch("add", "/s/0", map[string]any{}),
+	}, patch)
+}
+
+func TestNullAsValue_AbsentToNullInAtomic_GeneratesReplace(t *testing.T) {
+	a := `{"x":{"k":null}}`
+	b := `{"x":{}}`
+
+	collections := Collections{Atomics: []Path{"$.x"}}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/x", map[string]any{})}, patch)
+
+	equal, err := Equal([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+}
+
+func TestNullAsDelete_ValueToNull_GeneratesRemove(t *testing.T) {
+	a := `{"a":1, "b":"x", "c":{"d":true}}`
+	b := `{"a":1, "b":null, "c":{"d":null}}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{NullHandling: NullAsDelete}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []JsonPatchOperation{
+		NewPatch("remove", "/b", nil),
+		NewPatch("remove", "/c/d", nil),
+	}, patch)
+}
+
+func TestNullAsDelete_AbsentOrNullToNull_NoPatch(t *testing.T) {
+	a := `{"a":1, "c":null}`
+	b := `{"a":1, "b":null, "c":null}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{NullHandling: NullAsDelete}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestNullAsDelete_AddedValue_DropsNullMembers(t *testing.T) {
+	a := `{"a":1}`
+	b := `{"a":1, "b":{"c":null, "d":2}, "t":[{"k":1, "v":null}]}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{NullHandling: NullAsDelete}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []JsonPatchOperation{
+		NewPatch("add", "/b", map[string]any{"d": float64(2)}),
+		NewPatch("add", "/t", []any{map[string]any{"k": float64(1)}}),
+	}, patch)
+}
+
+func TestNullAsDelete_NullArrayElement_IsLiteral(t *testing.T) {
+	a := `{"l":[1, 2]}`
+	b := `{"l":[1, null]}`
+
+	collections := Collections{Arrays: []Path{"$.l"}, NullHandling: NullAsDelete}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/l/1", nil)}, patch)
+}
+
+func TestNullAsAbsent_NullOnEitherSide_NoPatch(t *testing.T) {
+	a := `{"a":1, "b":"x", "c":null, "n":{"d":null}}`
+	b := `{"a":1, "b":null, "n":{}, "e":null}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{NullHandling: NullAsAbsent}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestNullAsAbsent_NullToValue_GeneratesAdd(t *testing.T) {
+	a := `{"a":1, "b":null}`
+	b := `{"a":1, "b":2}`
+
+	patch, err := CreatePatch([]byte(a), []byte(b), Collections{NullHandling: NullAsAbsent}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/b", float64(2))}, patch)
+}
+
+func TestNullAsAbsent_NullMembersInEntitySetItems_NoPatch(t *testing.T) {
+	a := `{"t":[{"k":1, "v":null}, {"k":2, "v":2}]}`
+	b := `{"t":[{"k":2, "v":2}, {"k":1}]}`
+
+	collections := Collections{
+		EntitySets:   EntitySets{"$.t": "k"},
+		NullHandling: NullAsAbsent,
+	}
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
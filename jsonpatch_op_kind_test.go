@@ -0,0 +1,161 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parentOf returns the container holding the value at pointer.
+func parentOf(doc any, pointer string) any {
+	if pointer == "" {
+		return nil
+	}
+	parent, _, _ := pointerGet(doc, pointer[:strings.LastIndex(pointer, "/")])
+	return parent
+}
+
+func TestCreatePatch_NullBecomesValue_GeneratesReplace(t *testing.T) {
+	patch, err := CreatePatch([]byte(`{"x":null}`), []byte(`{"x":5}`), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/x", float64(5))}, patch)
+}
+
+func TestCreatePatch_NullArrayElementBecomesValue_GeneratesReplace(t *testing.T) {
+	collections := Collections{Arrays: []Path{"$.l"}}
+	patch, err := CreatePatch([]byte(`{"l":[1,null]}`), []byte(`{"l":[1,{"a":1}]}`), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "/l/1", map[string]any{"a": float64(1)})}, patch)
+}
+
+func TestCreatePatch_RootTypeChange_GeneratesRootReplace(t *testing.T) {
+	cases := map[string]struct {
+		a string
+		b string
+	}{
+		"null to object":  {`null`, `{"a":1}`},
+		"object to array": {`{"a":1}`, `[1]`},
+		"array to object": {`[1]`, `{"a":1}`},
+		"object to null":  {`{"a":1}`, `null`},
+		"string to float": {`"a"`, `1`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var expected any
+			assert.NoError(t, json.Unmarshal([]byte(tc.b), &expected))
+
+			patch, err := CreatePatch([]byte(tc.a), []byte(tc.b), Collections{}, nil, PatchStrategyExactMatch)
+			assert.NoError(t, err)
+			assert.Equal(t, []JsonPatchOperation{NewPatch("replace", "", expected)}, patch)
+		})
+	}
+}
+
+func TestCreatePatch_GeneratedPatchesApply(t *testing.T) {
+	cases := map[string]struct {
+		a           string
+		b           string
+		collections Collections
+		strategy    PatchStrategy
+		expected    string
+	}{
+		"scalar changes": {
+			a: `{"a":1, "b":"x", "c":true, "d":null}`,
+			b: `{"a":2, "b":"y", "c":false, "d":"z", "e":[1]}`,
+		},
+		"type changes": {
+			a: `{"a":1, "b":{"c":1}, "d":[1], "e":null}`,
+			b: `{"a":"1", "b":[1], "d":{"c":1}, "e":{"f":null}}`,
+		},
+		"nested objects": {
+			a:        `{"a":{"b":{"c":1, "d":2}}}`,
+			b:        `{"a":{"b":{"c":3, "e":4}, "f":5}}`,
+			expected: `{"a":{"b":{"c":3, "d":2, "e":4}, "f":5}}`,
+		},
+		"set in exact match": {
+			a: `{"s":[1, 2, 3]}`,
+			b: `{"s":[3, 4, 1, 5]}`,
+		},
+		"set in ensure exists": {
+			a:        `{"s":[1, 2, 3]}`,
+			b:        `{"s":[3, 4, 5]}`,
+			strategy: PatchStrategyEnsureExists,
+			expected: `{"s":[1, 2, 3, 4, 5]}`,
+		},
+		"array grows in exact match": {
+			a:           `{"l":[1, 2]}`,
+			b:           `{"l":[1, 2, 3, 4]}`,
+			collections: Collections{Arrays: []Path{"$.l"}},
+		},
+		"array grows in ensure exists": {
+			a:           `{"l":[1]}`,
+			b:           `{"l":[1, 2, 3]}`,
+			collections: Collections{Arrays: []Path{"$.l"}},
+			strategy:    PatchStrategyEnsureExists,
+		},
+		"array shrinks": {
+			a:           `{"l":[1, 2, 3, 4]}`,
+			b:           `{"l":[2, 4]}`,
+			collections: Collections{Arrays: []Path{"$.l"}},
+		},
+		"array elements change": {
+			a:           `{"l":[{"a":1}, null, 3]}`,
+			b:           `{"l":[{"a":2}, 2, null]}`,
+			collections: Collections{Arrays: []Path{"$.l"}},
+		},
+		"entity set": {
+			a:           `{"t":[{"k":1, "v":1}, {"k":2, "v":2}]}`,
+			b:           `{"t":[{"k":2, "v":3}, {"k":3, "v":3}]}`,
+			collections: Collections{EntitySets: EntitySets{"$.t": "k"}},
+		},
+		"null as delete": {
+			a:           `{"a":1, "b":2, "c":{"d":null}}`,
+			b:           `{"a":null, "b":3, "c":{"d":4, "e":null}}`,
+			collections: Collections{NullHandling: NullAsDelete},
+			expected:    `{"b":3, "c":{"d":4}}`,
+		},
+		"root array": {
+			a:           `[1, 2]`,
+			b:           `[1, 2, {"a":1}]`,
+			collections: Collections{Arrays: []Path{"$"}},
+		},
+		"root null": {
+			a: `null`,
+			b: `{"a":[1, 2]}`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			strategy := tc.strategy
+			if strategy == "" {
+				strategy = PatchStrategyExactMatch
+			}
+			expectedJson := tc.expected
+			if expectedJson == "" {
+				expectedJson = tc.b
+			}
+
+			patch, err := CreatePatch([]byte(tc.a), []byte(tc.b), tc.collections, nil, strategy)
+			assert.NoError(t, err)
+
+			var doc, expected any
+			assert.NoError(t, json.Unmarshal([]byte(tc.a), &doc))
+			assert.NoError(t, json.Unmarshal([]byte(expectedJson), &expected))
+			for _, op := range patch {
+				// add must only target absent members, replace only existing ones.
+				_, exists, err := pointerGet(doc, op.Path)
+				assert.NoError(t, err)
+				if _, isArray := parentOf(doc, op.Path).([]any); !isArray {
+					assert.Equal(t, op.Operation != "add", exists, "%s %s", op.Operation, op.Path)
+				}
+				doc, err = applyPatch(doc, []JsonPatchOperation{op})
+				assert.NoError(t, err, "patch %v", patch)
+			}
+			assert.True(t, matchesValue(expected, doc, true), "expected %v, got %v", expected, doc)
+		})
+	}
+}
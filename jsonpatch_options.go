@@ -0,0 +1,81 @@
+package jsonpatch
+
+// PatchOption shapes the patch CreatePatch generates, rather than how the
+// documents are compared, which is what Collections describe.
+type PatchOption func(*patchOptions)
+
+type patchOptions struct {
+	appendToken       bool
+	compactionRatio   float64
+	maxOperations     int
+	maxPatchBytes     int
+	replaceOverLimits bool
+
+	// exactMembers makes diff also remove the members missing from the
+	// modified document, for items paired by similarity, which must end up
+	// equal to the item they are paired with. It is set internally rather
+	// than by a PatchOption.
+	exactMembers bool
+}
+
+// WithAppendToken makes additions to sets and EntitySets use the "-" end of
+// array token instead of an index, so they still apply if the array has grown
+// in the meantime.
+func WithAppendToken() PatchOption {
+	return func(o *patchOptions) {
+		o.appendToken = true
+	}
+}
+
+// WithCompactionRatio enables folding runs of operations below a common
+// object or array into a single replace of it, when the replace serializes to
+// at most ratio times the size of the operations it stands for. Zero disables
+// compaction, the default.
+func WithCompactionRatio(ratio float64) PatchOption {
+	return func(o *patchOptions) {
+		o.compactionRatio = ratio
+	}
+}
+
+// WithMaxOperations limits the number of operations of the patch. Zero means
+// no limit, the default. A patch over the limit is an error wrapping
+// ErrPatchTooLarge, unless WithReplaceOverLimits is given.
+func WithMaxOperations(n int) PatchOption {
+	return func(o *patchOptions) {
+		o.maxOperations = n
+	}
+}
+
+// WithMaxPatchBytes limits the serialized size of the patch. Zero means no
+// limit, the default. A patch over the limit is an error wrapping
+// ErrPatchTooLarge, unless WithReplaceOverLimits is given.
+func WithMaxPatchBytes(n int) PatchOption {
+	return func(o *patchOptions) {
+		o.maxPatchBytes = n
+	}
+}
+
+// WithReplaceOverLimits returns a replace of the whole document instead of a
+// patch over the limits, if the replace fits the limits itself.
+func WithReplaceOverLimits() PatchOption {
+	return func(o *patchOptions) {
+		o.replaceOverLimits = true
+	}
+}
+
+func newPatchOptions(options []PatchOption) patchOptions {
+	result := patchOptions{}
+	for _, option := range options {
+		option(&result)
+	}
+	return result
+}
+
+// appendPath returns the path that appends an element to the array at path,
+// given that the element ends up at index.
+func (o patchOptions) appendPath(path string, index int) string {
+	if o.appendToken {
+		return makePath(path, "-")
+	}
+	return makePath(path, index)
+}
@@ -0,0 +1,143 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// walkSchema calls visit for every schema object found in the json schema
+// document, with the JSON Pointer of the values it describes, following
+// "properties", "additionalProperties", "items", "prefixItems", and the
+// "allOf", "anyOf" and "oneOf" combinators. The values of additional
+// properties are described by the "*" member, which becomes a ".*" JSONPath
+// member. Local "$ref" references are resolved against the document,
+// and a reference to a schema being walked already is not followed again, so
+// recursive schemas only describe their first level.
+//
+// The same pointer may be visited several times, once for each schema that
+// applies to it. The pointer is meant to be turned into a Path by toJsonPath,
+// so that paths match those of the generated patches exactly.
+func walkSchema(document []byte, visit func(schema map[string]any, pointer string) error) error {
+	var root any
+	if err := json.Unmarshal(document, &root); err != nil {
+		return errBadJsonDoc
+	}
+	return schemaWalker{root: root, visit: visit}.walk(root, "", nil)
+}
+
+type schemaWalker struct {
+	root  any
+	visit func(schema map[string]any, pointer string) error
+}
+
+func (w schemaWalker) walk(node any, pointer string, refs []string) error {
+	schema, ok := node.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok && !slices.Contains(refs, ref) {
+		resolved, err := w.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := w.walk(resolved, pointer, append(refs, ref)); err != nil {
+			return err
+		}
+	}
+	if err := w.visit(schema, pointer); err != nil {
+		return err
+	}
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		for _, name := range slices.Sorted(maps.Keys(properties)) {
+			if err := w.walk(properties[name], makePath(pointer, name), refs); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.walk(schema["additionalProperties"], makePath(pointer, "*"), refs); err != nil {
+		return err
+	}
+	subschemas := []any{schema["items"]}
+	if prefixItems, ok := schema["prefixItems"].([]any); ok {
+		subschemas = append(subschemas, prefixItems...)
+	}
+	for _, items := range subschemas {
+		if err := w.walk(items, makePath(pointer, 0), refs); err != nil {
+			return err
+		}
+	}
+	for _, combinator := range []string{"allOf", "anyOf", "oneOf"} {
+		alternatives, _ := schema[combinator].([]any)
+		for _, alternative := range alternatives {
+			if err := w.walk(alternative, pointer, refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema a local reference such as "#/$defs/Item", or
+// "#Item" for a schema with an "$anchor", points to.
+func (w schemaWalker) resolve(ref string) (any, error) {
+	fragment, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema reference %q: only local references are resolved", ref)
+	}
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		if schema := findAnchor(w.root, fragment); schema != nil {
+			return schema, nil
+		}
+		return nil, fmt.Errorf("unresolved schema reference %q", ref)
+	}
+	schema, found, err := pointerGet(w.root, fragment)
+	if err != nil || !found {
+		return nil, fmt.Errorf("unresolved schema reference %q", ref)
+	}
+	return schema, nil
+}
+
+// findAnchor returns the schema whose "$anchor" is anchor, if there is one.
+func findAnchor(node any, anchor string) map[string]any {
+	switch t := node.(type) {
+	case map[string]any:
+		if t["$anchor"] == anchor {
+			return t
+		}
+		for _, key := range slices.Sorted(maps.Keys(t)) {
+			if schema := findAnchor(t[key], anchor); schema != nil {
+				return schema
+			}
+		}
+	case []any:
+		for _, item := range t {
+			if schema := findAnchor(item, anchor); schema != nil {
+				return schema
+			}
+		}
+	}
+	return nil
+}
+
+// hasType reports whether the "type" of schema is name, or a list of types
+// that includes it, such as ["array", "null"].
+func hasType(schema map[string]any, name string) bool {
+	switch t := schema["type"].(type) {
+	case string:
+		return t == name
+	case []any:
+		return slices.Contains(t, any(name))
+	}
+	return false
+}
+
+// addPath adds path to paths unless it is there already.
+func addPath(paths []Path, path Path) []Path {
+	if slices.Contains(paths, path) {
+		return paths
+	}
+	return append(paths, path)
+}
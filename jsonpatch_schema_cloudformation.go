@@ -0,0 +1,76 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CollectionsFromCloudFormationSchema derives Collections from a CloudFormation
+// resource provider schema, along with the fields to ignore when diffing the
+// desired state of a resource against a Cloud Control read-back:
+//
+//   - Arrays with "insertionOrder": false are sets.
+//   - Arrays with "arrayType": "AttributeList" are Atomics.
+//   - Other arrays keep their insertion order, and are Arrays. That includes
+//     arrays with "uniqueItems": true, which only rules out duplicates.
+//   - "readOnlyProperties" are only known once the resource exists, and
+//     "writeOnlyProperties" are never read back, so both are ignored.
+//
+// An error will be returned if the schema is invalid or has references that
+// cannot be resolved.
+func CollectionsFromCloudFormationSchema(schema []byte) (Collections, []Path, error) {
+	collections := Collections{}
+	err := walkSchema(schema, func(schema map[string]any, pointer string) error {
+		if !hasType(schema, "array") {
+			return nil
+		}
+		path := Path(toJsonPath(pointer))
+		switch {
+		case schema["arrayType"] == "AttributeList":
+			collections.Atomics = addPath(collections.Atomics, path)
+		case schema["insertionOrder"] == false:
+		default:
+			collections.Arrays = addPath(collections.Arrays, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return Collections{}, nil, err
+	}
+
+	var properties struct {
+		ReadOnlyProperties  []string `json:"readOnlyProperties"`
+		WriteOnlyProperties []string `json:"writeOnlyProperties"`
+	}
+	if err := json.Unmarshal(schema, &properties); err != nil {
+		return Collections{}, nil, errBadJsonDoc
+	}
+	ignoredFields := []Path{}
+	for _, property := range append(properties.ReadOnlyProperties, properties.WriteOnlyProperties...) {
+		path, err := cloudFormationPropertyPath(property)
+		if err != nil {
+			return Collections{}, nil, err
+		}
+		ignoredFields = addPath(ignoredFields, path)
+	}
+	return collections, ignoredFields, nil
+}
+
+// cloudFormationPropertyPath converts a property pointer of a resource schema,
+// such as "/properties/Tags/*/Key", to a JSONPath such as "$.Tags[*].Key".
+func cloudFormationPropertyPath(property string) (Path, error) {
+	rest, ok := strings.CutPrefix(property, "/properties/")
+	if !ok {
+		return "", fmt.Errorf("invalid property pointer %q", property)
+	}
+	pointer := ""
+	for _, token := range strings.Split(rest, "/") {
+		if token == "*" {
+			pointer = makePath(pointer, 0)
+		} else {
+			pointer = makePath(pointer, rfc6901Decoder.Replace(token))
+		}
+	}
+	return Path(toJsonPath(pointer)), nil
+}
@@ -0,0 +1,83 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cloudFormationTestSchema = `{
+  "typeName": "Example::Service::Cluster",
+  "definitions": {
+    "Tag": {
+      "type": "object",
+      "properties": {"Key": {"type": "string"}, "Value": {"type": "string"}}
+    },
+    "Listener": {
+      "type": "object",
+      "properties": {
+        "Port": {"type": "integer"},
+        "Rules": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  },
+  "properties": {
+    "Arn": {"type": "string"},
+    "Name": {"type": "string"},
+    "Password": {"type": "string"},
+    "Tags": {"type": "array", "insertionOrder": false, "uniqueItems": true, "items": {"$ref": "#/definitions/Tag"}},
+    "Listeners": {"type": "array", "items": {"$ref": "#/definitions/Listener"}},
+    "Endpoints": {"type": "array", "arrayType": "AttributeList", "insertionOrder": false, "items": {"type": "string"}},
+    "Config": {
+      "type": "object",
+      "properties": {"Subnets": {"type": "array", "insertionOrder": true, "items": {"type": "string"}}}
+    }
+  },
+  "readOnlyProperties": ["/properties/Arn", "/properties/Listeners/*/Port"],
+  "writeOnlyProperties": ["/properties/Password"]
+}`
+
+func TestCollectionsFromCloudFormationSchema_MapsArrays(t *testing.T) {
+	collections, _, err := CollectionsFromCloudFormationSchema([]byte(cloudFormationTestSchema))
+	require.NoError(t, err)
+	assert.Empty(t, collections.EntitySets)
+	assert.ElementsMatch(t, []Path{"$.Endpoints"}, collections.Atomics)
+	assert.ElementsMatch(t, []Path{"$.Listeners", "$.Listeners[*].Rules", "$.Config.Subnets"}, collections.Arrays)
+}
+
+func TestCollectionsFromCloudFormationSchema_IgnoresReadOnlyAndWriteOnlyProperties(t *testing.T) {
+	_, ignored, err := CollectionsFromCloudFormationSchema([]byte(cloudFormationTestSchema))
+	require.NoError(t, err)
+	assert.Equal(t, []Path{"$.Arn", "$.Listeners[*].Port", "$.Password"}, ignored)
+}
+
+func TestCollectionsFromCloudFormationSchema_DrivesCreatePatch(t *testing.T) {
+	collections, ignored, err := CollectionsFromCloudFormationSchema([]byte(cloudFormationTestSchema))
+	require.NoError(t, err)
+
+	desired := `{"Name":"c", "Password":"secret", "Tags":[{"Key":"a", "Value":"1"}, {"Key":"b", "Value":"2"}], "Listeners":[{"Rules":["x", "y"]}]}`
+	actual := `{"Arn":"arn:c", "Name":"c", "Tags":[{"Key":"b", "Value":"2"}, {"Key":"a", "Value":"1"}], "Listeners":[{"Port":80, "Rules":["y", "x"]}]}`
+	patch, err := CreatePatch([]byte(actual), []byte(desired), collections, ignored, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/Listeners/0/Rules/0", "x"),
+		NewPatch("replace", "/Listeners/0/Rules/1", "y"),
+	}, patch)
+}
+
+func TestCollectionsFromCloudFormationSchema_InvalidPropertyPointer_ReturnsError(t *testing.T) {
+	_, _, err := CollectionsFromCloudFormationSchema([]byte(`{"readOnlyProperties": ["Arn"]}`))
+	assert.Error(t, err)
+}
+
+func TestCollectionsFromCloudFormationSchema_NullableArray_IsArray(t *testing.T) {
+	collections, _, err := CollectionsFromCloudFormationSchema([]byte(`{"properties": {
+	  "Rules": {"type": ["array", "null"], "items": {"type": "string"}},
+	  "Tags": {"type": ["array", "null"], "insertionOrder": false},
+	  "Endpoints": {"type": ["null", "array"], "arrayType": "AttributeList"}
+	}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Path{"$.Rules"}, collections.Arrays)
+	assert.Equal(t, []Path{"$.Endpoints"}, collections.Atomics)
+}
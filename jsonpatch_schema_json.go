@@ -0,0 +1,34 @@
+package jsonpatch
+
+// CollectionsFromJsonSchema derives Collections from a JSON Schema document
+// (draft 2020-12), following "properties", "additionalProperties", "items",
+// "prefixItems", combinators and local "$ref" references, such as to "$defs":
+//
+//   - Arrays with an "x-entity-key" keyword are EntitySets keyed by it.
+//   - Arrays and objects with "x-atomic": true are Atomics.
+//   - Arrays with "uniqueItems": true are sets.
+//   - Other arrays are ordered, and are Arrays.
+//
+// An error will be returned if the schema is invalid or has references that
+// cannot be resolved.
+func CollectionsFromJsonSchema(schema []byte) (Collections, error) {
+	collections := Collections{EntitySets: EntitySets{}}
+	err := walkSchema(schema, func(schema map[string]any, pointer string) error {
+		path := Path(toJsonPath(pointer))
+		key, isEntitySet := schema["x-entity-key"].(string)
+		switch {
+		case schema["x-atomic"] == true:
+			collections.Atomics = addPath(collections.Atomics, path)
+		case isEntitySet:
+			collections.EntitySets.Add(path, Key(key))
+		case !hasType(schema, "array") || schema["uniqueItems"] == true:
+		default:
+			collections.Arrays = addPath(collections.Arrays, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return Collections{}, err
+	}
+	return collections, nil
+}
@@ -0,0 +1,72 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jsonSchemaTestSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "users": {"type": "array", "x-entity-key": "id", "items": {"$ref": "#/$defs/User"}},
+    "tags": {"type": "array", "uniqueItems": true, "items": {"type": "string"}},
+    "steps": {"type": "array", "items": {"type": "string"}},
+    "policy": {"type": "object", "x-atomic": true},
+    "point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "array", "items": {"type": "number"}}]}
+  },
+  "$defs": {
+    "User": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "string"},
+        "roles": {"type": "array", "x-atomic": true, "items": {"type": "string"}},
+        "address": {"$ref": "#address"}
+      }
+    },
+    "Address": {
+      "$anchor": "address",
+      "allOf": [{"properties": {"lines": {"type": "array", "items": {"type": "string"}}}}]
+    }
+  }
+}`
+
+func TestCollectionsFromJsonSchema_MapsKeywords(t *testing.T) {
+	collections, err := CollectionsFromJsonSchema([]byte(jsonSchemaTestSchema))
+	require.NoError(t, err)
+	assert.Equal(t, EntitySets{"$.users": "id"}, collections.EntitySets)
+	assert.ElementsMatch(t, []Path{"$.policy", "$.users[*].roles"}, collections.Atomics)
+	assert.ElementsMatch(t, []Path{"$.steps", "$.point", "$.point[*]", "$.users[*].address.lines"}, collections.Arrays)
+}
+
+func TestCollectionsFromJsonSchema_DrivesCreatePatch(t *testing.T) {
+	collections, err := CollectionsFromJsonSchema([]byte(jsonSchemaTestSchema))
+	require.NoError(t, err)
+
+	a := `{"users":[{"id":"a", "roles":["x"]}, {"id":"b"}], "tags":["t1", "t2"], "steps":["s1", "s2"]}`
+	b := `{"users":[{"id":"b"}, {"id":"a", "roles":["x", "y"]}], "tags":["t2", "t1"], "steps":["s2", "s1"]}`
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/steps/0", "s2"),
+		NewPatch("replace", "/steps/1", "s1"),
+		NewPatch("replace", "/users/0/roles", []any{"x", "y"}),
+	}, patch)
+}
+
+func TestCollectionsFromJsonSchema_UnresolvedAnchor_ReturnsError(t *testing.T) {
+	_, err := CollectionsFromJsonSchema([]byte(`{"properties": {"a": {"$ref": "#missing"}}}`))
+	assert.Error(t, err)
+}
+
+func TestCollectionsFromJsonSchema_NullableArray_IsArray(t *testing.T) {
+	collections, err := CollectionsFromJsonSchema([]byte(`{"properties": {
+	  "steps": {"type": ["array", "null"], "items": {"type": "string"}},
+	  "tags": {"type": ["null", "array"], "uniqueItems": true},
+	  "name": {"type": ["string", "null"]}
+	}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Path{"$.steps"}, collections.Arrays)
+}
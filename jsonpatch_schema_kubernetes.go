@@ -0,0 +1,50 @@
+package jsonpatch
+
+// CollectionsFromKubernetesSchema derives Collections from a Kubernetes
+// OpenAPI v3 schema, such as the openAPIV3Schema of a CustomResourceDefinition,
+// from its list and map type extensions. The values of maps, described by
+// "additionalProperties", are addressed with a ".*" member:
+//
+//   - "x-kubernetes-list-type: map" lists are EntitySets keyed by their
+//     "x-kubernetes-list-map-keys". EntitySets have a single key, so lists
+//     with composite keys, such as the ports of a container keyed by
+//     "containerPort" and "protocol", are left as sets and their paths are
+//     returned: an item that changes is removed and added again rather than
+//     updated in place, unless the caller declares them otherwise.
+//   - "x-kubernetes-list-type: set" lists are Sets.
+//   - "x-kubernetes-list-type: atomic" lists and "x-kubernetes-map-type:
+//     atomic" objects are Atomics.
+//   - Other lists are ordered, and are Arrays.
+//
+// An error will be returned if the schema is invalid or has references that
+// cannot be resolved.
+func CollectionsFromKubernetesSchema(schema []byte) (Collections, []Path, error) {
+	collections := Collections{EntitySets: EntitySets{}}
+	compositeKeys := []Path{}
+	err := walkSchema(schema, func(schema map[string]any, pointer string) error {
+		path := Path(toJsonPath(pointer))
+		listType, isList := schema["x-kubernetes-list-type"].(string)
+		switch {
+		case listType == "map":
+			keys, _ := schema["x-kubernetes-list-map-keys"].([]any)
+			if len(keys) != 1 {
+				compositeKeys = addPath(compositeKeys, path)
+				break
+			}
+			if key, ok := keys[0].(string); ok {
+				collections.EntitySets.Add(path, Key(key))
+			}
+		case listType == "set":
+			collections.Sets = addPath(collections.Sets, path)
+		case listType == "atomic" || schema["x-kubernetes-map-type"] == "atomic":
+			collections.Atomics = addPath(collections.Atomics, path)
+		case !isList && hasType(schema, "array"):
+			collections.Arrays = addPath(collections.Arrays, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return Collections{}, nil, err
+	}
+	return collections, compositeKeys, nil
+}
@@ -0,0 +1,145 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var kubernetesTestSchema = `{
+  "type": "object",
+  "properties": {
+    "spec": {
+      "type": "object",
+      "properties": {
+        "selector": {"type": "object", "x-kubernetes-map-type": "atomic"},
+        "containers": {
+          "type": "array",
+          "x-kubernetes-list-type": "map",
+          "x-kubernetes-list-map-keys": ["name"],
+          "items": {"$ref": "#/definitions/Container"}
+        },
+        "ports": {
+          "type": "array",
+          "x-kubernetes-list-type": "map",
+          "x-kubernetes-list-map-keys": ["containerPort", "protocol"],
+          "items": {"type": "object"}
+        },
+        "finalizers": {"type": "array", "x-kubernetes-list-type": "set", "items": {"type": "string"}},
+        "hosts": {"type": "array", "x-kubernetes-list-type": "atomic", "items": {"type": "string"}}
+      }
+    }
+  },
+  "definitions": {
+    "Container": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "args": {"type": "array", "items": {"type": "string"}},
+        "env": {
+          "type": "array",
+          "x-kubernetes-list-type": "map",
+          "x-kubernetes-list-map-keys": ["name"],
+          "items": {"type": "object"}
+        }
+      }
+    }
+  }
+}`
+
+func TestCollectionsFromKubernetesSchema_MapsListTypes(t *testing.T) {
+	collections, _, err := CollectionsFromKubernetesSchema([]byte(kubernetesTestSchema))
+	require.NoError(t, err)
+	assert.Equal(t, EntitySets{
+		"$.spec.containers":        "name",
+		"$.spec.containers[*].env": "name",
+	}, collections.EntitySets)
+	assert.ElementsMatch(t, []Path{"$.spec.selector", "$.spec.hosts"}, collections.Atomics)
+	assert.ElementsMatch(t, []Path{"$.spec.containers[*].args"}, collections.Arrays)
+	assert.Equal(t, []Path{"$.spec.finalizers"}, collections.Sets)
+}
+
+func TestCollectionsFromKubernetesSchema_DrivesCreatePatch(t *testing.T) {
+	collections, _, err := CollectionsFromKubernetesSchema([]byte(kubernetesTestSchema))
+	require.NoError(t, err)
+
+	a := `{"spec":{"containers":[{"name":"app", "args":["-a"]}, {"name":"proxy"}]}}`
+	b := `{"spec":{"containers":[{"name":"proxy"}, {"name":"app", "args":["-a", "-b"]}]}}`
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{NewPatch("add", "/spec/containers/0/args/1", "-b")}, patch)
+}
+
+func TestCollectionsFromKubernetesSchema_RecursiveReference_Terminates(t *testing.T) {
+	schema := `{
+	  "$ref": "#/definitions/Node",
+	  "definitions": {
+	    "Node": {
+	      "type": "object",
+	      "properties": {
+	        "children": {"type": "array", "x-kubernetes-list-type": "atomic", "items": {"$ref": "#/definitions/Node"}}
+	      }
+	    }
+	  }
+	}`
+	collections, _, err := CollectionsFromKubernetesSchema([]byte(schema))
+	require.NoError(t, err)
+	assert.Equal(t, []Path{"$.children"}, collections.Atomics)
+}
+
+func TestCollectionsFromKubernetesSchema_AdditionalProperties_MatchesEveryMember(t *testing.T) {
+	schema := `{
+	  "type": "object",
+	  "properties": {
+	    "volumes": {
+	      "type": "object",
+	      "additionalProperties": {
+	        "type": "object",
+	        "properties": {
+	          "mounts": {"type": "array", "items": {"type": "string"}},
+	          "hosts": {"type": "array", "x-kubernetes-list-type": "map", "x-kubernetes-list-map-keys": ["name"]}
+	        }
+	      }
+	    }
+	  }
+	}`
+	collections, _, err := CollectionsFromKubernetesSchema([]byte(schema))
+	require.NoError(t, err)
+	assert.Equal(t, []Path{"$.volumes.*.mounts"}, collections.Arrays)
+	assert.Equal(t, EntitySets{"$.volumes.*.hosts": "name"}, collections.EntitySets)
+
+	a := `{"volumes":{"data":{"mounts":["/a", "/b"]}}}`
+	b := `{"volumes":{"data":{"mounts":["/b", "/a"]}}}`
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Len(t, patch, 2)
+}
+
+func TestCollectionsFromKubernetesSchema_CompositeMapKeys_ReturnedAndComparedAsSets(t *testing.T) {
+	collections, compositeKeys, err := CollectionsFromKubernetesSchema([]byte(kubernetesTestSchema))
+	require.NoError(t, err)
+	assert.Equal(t, []Path{"$.spec.ports"}, compositeKeys)
+	_, ok := collections.EntitySets.Get("$.spec.ports")
+	assert.False(t, ok)
+
+	a := `{"spec":{"ports":[{"containerPort":80, "protocol":"TCP", "name":"http"}]}}`
+	b := `{"spec":{"ports":[{"containerPort":80, "protocol":"TCP", "name":"web"}]}}`
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("remove", "/spec/ports/0", nil),
+		NewPatch("add", "/spec/ports/0", map[string]any{"containerPort": float64(80), "protocol": "TCP", "name": "web"}),
+	}, patch)
+}
+
+func TestCollectionsFromKubernetesSchema_InvalidSchema_ReturnsError(t *testing.T) {
+	_, _, err := CollectionsFromKubernetesSchema([]byte(`{`))
+	assert.Error(t, err)
+
+	_, _, err = CollectionsFromKubernetesSchema([]byte(`{"$ref": "#/definitions/Missing"}`))
+	assert.Error(t, err)
+
+	_, _, err = CollectionsFromKubernetesSchema([]byte(`{"$ref": "other.json#/definitions/Item"}`))
+	assert.Error(t, err)
+}
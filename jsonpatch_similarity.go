@@ -0,0 +1,187 @@
+package jsonpatch
+
+import "math"
+
+// defaultSimilarityThreshold is used when Collections.SimilarityThreshold is not set.
+const defaultSimilarityThreshold = 0.5
+
+func (c *Collections) similarityThreshold() float64 {
+	if c.SimilarityThreshold > 0 {
+		return c.SimilarityThreshold
+	}
+	return defaultSimilarityThreshold
+}
+
+func (c *Collections) isFuzzySet(path string) bool {
+	return containsPath(c.FuzzySets, toJsonPath(path))
+}
+
+func (c *Collections) isMutableKey(path string) bool {
+	return containsPath(c.MutableKeys, toJsonPath(path))
+}
+
+// similarity returns how alike two json values are, from 0 when they have
+// nothing in common to 1 when they are equal. Objects score the average
+// similarity of their members, arrays the share of elements they have in common.
+func similarity(av, bv any) float64 {
+	switch at := av.(type) {
+	case map[string]any:
+		bt, ok := bv.(map[string]any)
+		if !ok {
+			return 0
+		}
+		keys := 0
+		total := 0.0
+		for key, value := range at {
+			keys++
+			if other, ok := bt[key]; ok {
+				total += similarity(value, other)
+			}
+		}
+		for key := range bt {
+			if _, ok := at[key]; !ok {
+				keys++
+			}
+		}
+		if keys == 0 {
+			return 1
+		}
+		return total / float64(keys)
+	case []any:
+		bt, ok := bv.([]any)
+		if !ok {
+			return 0
+		}
+		if len(at)+len(bt) == 0 {
+			return 1
+		}
+		matched := make([]bool, len(bt))
+		common := 0
+		for _, ea := range at {
+			for j, eb := range bt {
+				if !matched[j] && matchesValue(ea, eb, true) {
+					matched[j] = true
+					common++
+					break
+				}
+			}
+		}
+		return float64(2*common) / float64(len(at)+len(bt))
+	default:
+		if matchesValue(av, bv, true) {
+			return 1
+		}
+		return 0
+	}
+}
+
+// pairBySimilarity pairs items of `as` with items of `bs` so that the total
+// similarity of the pairs is as high as possible. Pairs scoring below threshold
+// are left unpaired.
+func pairBySimilarity(as, bs []int, score func(i, j int) float64, threshold float64) [][2]int {
+	if len(as) == 0 || len(bs) == 0 {
+		return [][2]int{}
+	}
+	weights := make([][]float64, len(as))
+	for x, i := range as {
+		weights[x] = make([]float64, len(bs))
+		for y, j := range bs {
+			if s := score(i, j); s >= threshold {
+				weights[x][y] = s
+			}
+		}
+	}
+
+	pairs := [][2]int{}
+	for x, y := range assign(weights) {
+		if y >= 0 && weights[x][y] > 0 {
+			pairs = append(pairs, [2]int{as[x], bs[y]})
+		}
+	}
+	return pairs
+}
+
+// assign solves the assignment problem with the Hungarian algorithm. It
+// returns, for each row of weights, the column assigned to it or -1, such that
+// the sum of the assigned weights is maximal. Runs in O(n²m).
+func assign(weights [][]float64) []int {
+	rows, cols := len(weights), len(weights[0])
+	if rows > cols {
+		// The algorithm needs at least as many columns as rows.
+		transposed := make([][]float64, cols)
+		for y := range transposed {
+			transposed[y] = make([]float64, rows)
+			for x := range weights {
+				transposed[y][x] = weights[x][y]
+			}
+		}
+		result := make([]int, rows)
+		for x := range result {
+			result[x] = -1
+		}
+		for y, x := range assign(transposed) {
+			if x >= 0 {
+				result[x] = y
+			}
+		}
+		return result
+	}
+
+	// Potentials u and v, and the row matched to each column, all 1-based
+	// with column 0 as a sentinel, minimising the negated weights.
+	u := make([]float64, rows+1)
+	v := make([]float64, cols+1)
+	rowOf := make([]int, cols+1)
+	way := make([]int, cols+1)
+	for x := 1; x <= rows; x++ {
+		rowOf[0] = x
+		y0 := 0
+		minv := make([]float64, cols+1)
+		for y := range minv {
+			minv[y] = math.Inf(1)
+		}
+		used := make([]bool, cols+1)
+		for rowOf[y0] != 0 {
+			used[y0] = true
+			x0, delta, y1 := rowOf[y0], math.Inf(1), 0
+			for y := 1; y <= cols; y++ {
+				if used[y] {
+					continue
+				}
+				if cur := -weights[x0-1][y-1] - u[x0] - v[y]; cur < minv[y] {
+					minv[y] = cur
+					way[y] = y0
+				}
+				if minv[y] < delta {
+					delta = minv[y]
+					y1 = y
+				}
+			}
+			for y := 0; y <= cols; y++ {
+				if used[y] {
+					u[rowOf[y]] += delta
+					v[y] -= delta
+				} else {
+					minv[y] -= delta
+				}
+			}
+			y0 = y1
+		}
+		for y0 != 0 {
+			y1 := way[y0]
+			rowOf[y0] = rowOf[y1]
+			y0 = y1
+		}
+	}
+
+	result := make([]int, rows)
+	for x := range result {
+		result[x] = -1
+	}
+	for y := 1; y <= cols; y++ {
+		if rowOf[y] != 0 {
+			result[rowOf[y]-1] = y - 1
+		}
+	}
+	return result
+}
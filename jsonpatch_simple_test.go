@@ -102,7 +102,7 @@ func BenchmarkBigArrays(b *testing.B) {
 		a2[i+1] = i
 	}
 	for i := 0; i < b.N; i++ {
-		compareArray(a1, a2, "/", PatchStrategyExactMatch, Collections{})
+		compareArray(a1, a2, "/", PatchStrategyExactMatch, Collections{}, patchOptions{})
 	}
 }
 
@@ -116,6 +116,6 @@ func BenchmarkBigArrays2(b *testing.B) {
 		a2[i] = i
 	}
 	for i := 0; i < b.N; i++ {
-		compareArray(a1, a2, "/", PatchStrategyExactMatch, Collections{})
+		compareArray(a1, a2, "/", PatchStrategyExactMatch, Collections{}, patchOptions{})
 	}
 }
@@ -0,0 +1,211 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// CreateStrategicMergePatch creates a Kubernetes strategic merge patch.
+//
+// 'a' is original, 'b' is the modified document. Both are to be given as json
+// encoded content. The changes are the ones CreatePatch generates for the same
+// collections, ignored fields and strategy, expressed with the directives of
+// strategic merge patches:
+//
+//   - EntitySets and KeyedArrays are merged by key, like lists with a
+//     patchMergeKey. Only changed items are sent, and removed items are marked
+//     with "$patch": "delete". The order of KeyedArrays is set with a
+//     "$setElementOrder/<member>" directive.
+//   - Sets of scalar values declared in Collections.Sets are merged too, with
+//     removed values listed in a "$deleteFromPrimitiveList/<member>" directive.
+//   - Arrays, Atomics, and other lists are atomic lists, replaced as a whole.
+//     Atomic objects are replaced with "$patch": "replace".
+//
+// An error will be returned if any of the two documents are invalid, or if
+// the patch would need to set a member to null.
+func CreateStrategicMergePatch(a, b []byte, collections Collections, ignoredFields []Path, strategy PatchStrategy) ([]byte, error) {
+	aUnmarshalled, aWithoutIgnoredFields, bWithoutIgnoredFields, err := prepareDocuments(a, b, collections, ignoredFields)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := handleValues(aWithoutIgnoredFields, bWithoutIgnoredFields, "", []JsonPatchOperation{}, strategy, collections, patchOptions{})
+	if err != nil {
+		return nil, err
+	}
+	patched, err := applyPatch(deepCopy(aUnmarshalled), patch)
+	if err != nil {
+		return nil, fmt.Errorf("error applying patch to original document: %w", err)
+	}
+	strategicPatch, err := strategicDiff(aUnmarshalled, patched, "", collections)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(strategicPatch)
+}
+
+// strategicDiff returns the strategic merge patch that turns av into bv.
+func strategicDiff(av, bv any, p string, collections Collections) (any, error) {
+	at, aIsObject := av.(map[string]any)
+	bt, bIsObject := bv.(map[string]any)
+	if !aIsObject || !bIsObject {
+		return bv, checkNoNullMembers(bv, p)
+	}
+	if collections.isAtomic(p) {
+		if err := checkNoNullMembers(bt, p); err != nil {
+			return nil, err
+		}
+		replace := maps.Clone(bt)
+		replace["$patch"] = "replace"
+		return replace, nil
+	}
+	result := map[string]any{}
+	for _, key := range slices.Sorted(maps.Keys(bt)) {
+		bValue := bt[key]
+		path := makePath(p, key)
+		aValue, ok := at[key]
+		aList, aIsList := aValue.([]any)
+		bList, bIsList := bValue.([]any)
+		switch {
+		case bValue == nil && (!ok || aValue != nil):
+			return nil, fmt.Errorf("%w: %s", ErrNullNotRepresentable, path)
+		case !ok:
+			if err := checkNoNullMembers(bValue, path); err != nil {
+				return nil, err
+			}
+			result[key] = bValue
+		case reflect.DeepEqual(aValue, bValue):
+		case aIsList && bIsList:
+			value, directives, err := strategicListDiff(aList, bList, path, collections)
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				result[key] = value
+			}
+			for directive, directiveValue := range directives {
+				result[directive+"/"+key] = directiveValue
+			}
+		default:
+			changes, err := strategicDiff(aValue, bValue, path, collections)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = changes
+		}
+	}
+	for key := range at {
+		if _, ok := bt[key]; !ok {
+			result[key] = nil
+		}
+	}
+	return result, nil
+}
+
+// strategicListDiff returns the value of the list member of a strategic merge
+// patch that turns av into bv, or nil if it can be left out, along with the
+// directives to set next to it by name.
+func strategicListDiff(av, bv []any, p string, collections Collections) (any, map[string]any, error) {
+	switch {
+	case collections.isArray(p) || collections.isAtomic(p):
+		return bv, nil, nil
+	case collections.isEntitySet(p):
+		key, _ := collections.EntitySets.Get(Path(toJsonPath(p)))
+		return strategicMergeList(av, bv, p, key, false, collections)
+	case collections.isKeyedArray(p):
+		key, _ := collections.KeyedArrays.Get(Path(toJsonPath(p)))
+		return strategicMergeList(av, bv, p, key, true, collections)
+	case !collections.isSet(p) || !isScalarList(av) || !isScalarList(bv):
+		// Lists the receiving end does not merge as sets, and lists of other
+		// values, can only be replaced.
+		return bv, nil, nil
+	}
+
+	added := []any{}
+	processSet(bv, av, func(_ int, value any) {
+		if !slices.Contains(added, value) {
+			added = append(added, value)
+		}
+	})
+	removed := []any{}
+	processSet(av, bv, func(_ int, value any) {
+		if !slices.Contains(removed, value) {
+			removed = append(removed, value)
+		}
+	})
+	directives := map[string]any{}
+	if len(removed) > 0 {
+		directives["$deleteFromPrimitiveList"] = removed
+	}
+	if len(added) == 0 {
+		return nil, directives, nil
+	}
+	return added, directives, nil
+}
+
+// strategicMergeList returns the changed and deleted items of a list merged
+// by key. Lists with items that are not objects holding the key are replaced
+// as a whole.
+func strategicMergeList(av, bv []any, p string, key Key, ordered bool, collections Collections) (any, map[string]any, error) {
+	for _, item := range slices.Concat(av, bv) {
+		object, ok := item.(map[string]any)
+		if _, hasKey := object[string(key)]; !ok || !hasKey {
+			return append([]any{map[string]any{"$patch": "replace"}}, bv...), nil, nil
+		}
+	}
+
+	matches := processIdentitySet(av, bv, key)
+	items := []any{}
+	for j, bItem := range bv {
+		path := makePath(p, j)
+		i := slices.Index(matches, j)
+		switch {
+		case i < 0:
+			if err := checkNoNullMembers(bItem, path); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, bItem)
+		case !reflect.DeepEqual(av[i], bItem):
+			changes, err := strategicDiff(av[i], bItem, path, collections)
+			if err != nil {
+				return nil, nil, err
+			}
+			changes.(map[string]any)[string(key)] = bItem.(map[string]any)[string(key)]
+			items = append(items, changes)
+		}
+	}
+	for i, j := range matches {
+		if j < 0 {
+			items = append(items, map[string]any{
+				string(key): av[i].(map[string]any)[string(key)],
+				"$patch":    "delete",
+			})
+		}
+	}
+
+	directives := map[string]any{}
+	if ordered {
+		order := make([]any, len(bv))
+		for j, bItem := range bv {
+			order[j] = map[string]any{string(key): bItem.(map[string]any)[string(key)]}
+		}
+		directives["$setElementOrder"] = order
+	}
+	if len(items) == 0 {
+		return nil, directives, nil
+	}
+	return items, directives, nil
+}
+
+// isScalarList reports whether the list holds no objects or arrays.
+func isScalarList(list []any) bool {
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]any, []any:
+			return false
+		}
+	}
+	return true
+}
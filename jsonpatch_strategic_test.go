@@ -0,0 +1,103 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var strategicTestCollections = Collections{
+	EntitySets:  EntitySets{"$.spec.containers": "name", "$.spec.containers[*].env": "name"},
+	KeyedArrays: EntitySets{"$.spec.initContainers": "name"},
+	Arrays:      []Path{"$.spec.containers[*].args"},
+	Atomics:     []Path{"$.spec.selector"},
+}
+
+func TestCreateStrategicMergePatch_EntitySetItems_MergedByKey(t *testing.T) {
+	a := `{"spec":{"containers":[
+		{"name":"app", "image":"app:1", "env":[{"name":"A", "value":"1"}, {"name":"B", "value":"2"}]},
+		{"name":"proxy", "image":"proxy:1"},
+		{"name":"log", "image":"log:1"}]}}`
+	b := `{"spec":{"containers":[
+		{"name":"app", "image":"app:1", "env":[{"name":"A", "value":"3"}]},
+		{"name":"log", "image":"log:1"},
+		{"name":"metrics", "image":"metrics:1"}]}}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), strategicTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"containers":[
+		{"name":"app", "env":[{"name":"A", "value":"3"}, {"name":"B", "$patch":"delete"}]},
+		{"name":"metrics", "image":"metrics:1"},
+		{"name":"proxy", "$patch":"delete"}]}}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_EnsureExists_NoDeleteDirectives(t *testing.T) {
+	a := `{"spec":{"containers":[{"name":"app", "image":"app:1"}, {"name":"proxy", "image":"proxy:1"}]}}`
+	b := `{"spec":{"containers":[{"name":"app", "image":"app:2"}]}}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), strategicTestCollections, nil, PatchStrategyEnsureExists)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"containers":[{"name":"app", "image":"app:2"}]}}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_KeyedArray_SetsElementOrder(t *testing.T) {
+	a := `{"spec":{"initContainers":[{"name":"a"}, {"name":"b"}]}}`
+	b := `{"spec":{"initContainers":[{"name":"b"}, {"name":"a"}]}}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), strategicTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"$setElementOrder/initContainers":[{"name":"b"}, {"name":"a"}]}}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_PrimitiveSet_DeleteFromPrimitiveList(t *testing.T) {
+	a := `{"finalizers":["a", "b", "c"]}`
+	b := `{"finalizers":["c", "d", "a"]}`
+
+	collections := Collections{Sets: []Path{"$.finalizers"}}
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), collections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"finalizers":["d"], "$deleteFromPrimitiveList/finalizers":["b"]}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_UndeclaredSet_ReplacedAsAWhole(t *testing.T) {
+	a := `{"finalizers":["a", "b", "c"]}`
+	b := `{"finalizers":["c", "d", "a"]}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"finalizers":["a", "c", "d"]}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_AtomicsAndArrays_ReplacedAsAWhole(t *testing.T) {
+	a := `{"spec":{"selector":{"app":"web", "tier":"front"}, "containers":[{"name":"app", "args":["-a", "-b"]}]}}`
+	b := `{"spec":{"selector":{"app":"web"}, "containers":[{"name":"app", "args":["-b"]}]}}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), strategicTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{
+		"selector":{"app":"web", "$patch":"replace"},
+		"containers":[{"name":"app", "args":["-b"]}]}}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_ItemsWithoutKey_ListReplaced(t *testing.T) {
+	a := `{"spec":{"containers":[{"name":"app"}]}}`
+	b := `{"spec":{"containers":[{"name":"app"}, {"image":"x"}]}}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), strategicTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"containers":[{"$patch":"replace"}, {"name":"app"}, {"image":"x"}]}}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_NoChanges_EmptyPatch(t *testing.T) {
+	a := `{"spec":{"containers":[{"name":"app"}, {"name":"proxy"}]}}`
+	b := `{"spec":{"containers":[{"name":"proxy"}, {"name":"app"}]}}`
+
+	patch, err := CreateStrategicMergePatch([]byte(a), []byte(b), strategicTestCollections, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(patch))
+}
+
+func TestCreateStrategicMergePatch_NullValue_ReturnsError(t *testing.T) {
+	_, err := CreateStrategicMergePatch([]byte(`{"a":1}`), []byte(`{"a":null}`), Collections{}, nil, PatchStrategyExactMatch)
+	assert.ErrorIs(t, err, ErrNullNotRepresentable)
+}
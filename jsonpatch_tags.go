@@ -0,0 +1,185 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CollectionsFor derives Collections and ignored fields from the Go type T,
+// following the json names of its fields through nested structs, slices and
+// maps. Fields declare how they are diffed with a jsonpatch tag:
+//
+//	Ports     []Port            `json:"ports" jsonpatch:"entityset,key=name"`
+//	Steps     []Step            `json:"steps" jsonpatch:"keyedarray,key=id"`
+//	Args      []string          `json:"args" jsonpatch:"array"`
+//	Tags      []string          `json:"tags" jsonpatch:"set"`
+//	Policy    map[string]string `json:"policy" jsonpatch:"atomic"`
+//	UpdatedAt string            `json:"updatedAt" jsonpatch:"ignore"`
+//
+// The key of entityset and keyedarray items is the json name or the Go name
+// of a field of the items, and is recorded by its json name.
+//
+// Slices without a tag are compared as sets, the default, and the ones tagged
+// "set" are Sets too. The values of maps are addressed with a ".*" member,
+// such as "$.services.*.ports". Recursive types only describe their first
+// level.
+//
+// An error will be returned if a tag is invalid, or names a key field the
+// items do not have.
+func CollectionsFor[T any]() (Collections, []Path, error) {
+	builder := collectionsBuilder{
+		collections: Collections{EntitySets: EntitySets{}, KeyedArrays: EntitySets{}},
+		ignored:     []Path{},
+		walking:     map[reflect.Type]bool{},
+	}
+	if err := builder.walk(reflect.TypeFor[T](), ""); err != nil {
+		return Collections{}, nil, err
+	}
+	return builder.collections, builder.ignored, nil
+}
+
+type collectionsBuilder struct {
+	collections Collections
+	ignored     []Path
+	// walking holds the types being walked, so recursive types end.
+	walking map[reflect.Type]bool
+}
+
+var jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+
+func (b *collectionsBuilder) walk(t reflect.Type, pointer string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// Types with their own encoding can have any shape.
+	if b.walking[t] || t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return nil
+	}
+	b.walking[t] = true
+	defer delete(b.walking, t)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return b.walkFields(t, pointer)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nil // encoded as a string
+		}
+		return b.walk(t.Elem(), makePath(pointer, 0))
+	case reflect.Map:
+		return b.walk(t.Elem(), makePath(pointer, "*"))
+	}
+	return nil
+}
+
+func (b *collectionsBuilder) walkFields(t reflect.Type, pointer string) error {
+	for field := range fieldsOf(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			// Fields of embedded structs are encoded as fields of t.
+			if err := b.walk(field.Type, pointer); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPointer := makePath(pointer, name)
+		recurse, err := b.applyTag(field, Path(toJsonPath(fieldPointer)))
+		if err != nil {
+			return err
+		}
+		if recurse {
+			if err := b.walk(field.Type, fieldPointer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyTag records the jsonpatch tag of field at path, and reports whether
+// the value of the field is to be walked.
+func (b *collectionsBuilder) applyTag(field reflect.StructField, path Path) (bool, error) {
+	tag, ok := field.Tag.Lookup("jsonpatch")
+	if !ok {
+		return true, nil
+	}
+	kind, options, _ := strings.Cut(tag, ",")
+	key, hasKey := strings.CutPrefix(options, "key=")
+	if (kind == "entityset" || kind == "keyedarray") != (hasKey && key != "") {
+		return false, fmt.Errorf("invalid jsonpatch tag %q on field %s: only entityset and keyedarray take a key", tag, field.Name)
+	}
+	switch kind {
+	case "set":
+		b.collections.Sets = addPath(b.collections.Sets, path)
+	case "array":
+		b.collections.Arrays = addPath(b.collections.Arrays, path)
+	case "atomic":
+		b.collections.Atomics = addPath(b.collections.Atomics, path)
+		return false, nil
+	case "entityset", "keyedarray":
+		name, err := itemKey(field.Type, key)
+		if err != nil {
+			return false, fmt.Errorf("invalid jsonpatch tag %q on field %s: %w", tag, field.Name, err)
+		}
+		if kind == "entityset" {
+			b.collections.EntitySets.Add(path, name)
+		} else {
+			b.collections.KeyedArrays.Add(path, name)
+		}
+	case "ignore":
+		b.ignored = addPath(b.ignored, path)
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid jsonpatch tag %q on field %s", tag, field.Name)
+	}
+	return true, nil
+}
+
+// itemKey returns the json name of the key field of the items of the slice
+// type t, given either its json name or its Go name. Keys of items that are
+// not structs, such as maps, cannot be checked and are returned as is.
+func itemKey(t reflect.Type, key string) (Key, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return Key(key), nil
+	}
+	fields := structFields(t)
+	for _, field := range fields {
+		if field.name == key {
+			return Key(field.name), nil
+		}
+	}
+	for _, field := range fields {
+		if t.FieldByIndex(field.index).Name == key {
+			return Key(field.name), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no field %s", t, key)
+}
+
+// fieldsOf returns the fields of the struct type t.
+func fieldsOf(t reflect.Type) func(yield func(reflect.StructField) bool) {
+	return func(yield func(reflect.StructField) bool) {
+		for i := range t.NumField() {
+			if !yield(t.Field(i)) {
+				return
+			}
+		}
+	}
+}
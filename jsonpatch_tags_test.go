@@ -0,0 +1,140 @@
+package jsonpatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taggedPort struct {
+	Name     string   `json:"name"`
+	Port     int      `json:"port"`
+	Hosts    []string `json:"hosts" jsonpatch:"array"`
+	Internal string   `json:"-"`
+}
+
+type taggedMetadata struct {
+	Labels    map[string]string `json:"labels" jsonpatch:"atomic"`
+	UpdatedAt time.Time         `json:"updatedAt" jsonpatch:"ignore"`
+}
+
+type taggedService struct {
+	taggedMetadata
+	Image   string                  `json:"image"`
+	Args    []string                `json:"args" jsonpatch:"array"`
+	Tags    []string                `json:"tags" jsonpatch:"set"`
+	Ports   []taggedPort            `json:"ports" jsonpatch:"entityset,key=name"`
+	Steps   []taggedStep            `json:"steps,omitempty" jsonpatch:"keyedarray,key=id"`
+	Volumes map[string]taggedVolume `json:"volumes"`
+	Owner   *taggedOwner            `json:"owner"`
+	secret  string
+}
+
+type taggedStep struct {
+	ID string `json:"id"`
+}
+
+type taggedVolume struct {
+	Mounts []string `json:"mounts" jsonpatch:"array"`
+}
+
+type taggedOwner struct {
+	Name     string       `json:"name"`
+	Delegate *taggedOwner `json:"delegate"`
+	Teams    []string     `jsonpatch:"array"`
+}
+
+func TestCollectionsFor_MapsTags(t *testing.T) {
+	collections, ignored, err := CollectionsFor[taggedService]()
+	require.NoError(t, err)
+	assert.Equal(t, EntitySets{"$.ports": "name"}, collections.EntitySets)
+	assert.Equal(t, EntitySets{"$.steps": "id"}, collections.KeyedArrays)
+	assert.ElementsMatch(t, []Path{"$.labels"}, collections.Atomics)
+	assert.Equal(t, []Path{"$.tags"}, collections.Sets)
+	assert.ElementsMatch(t, []Path{
+		"$.args",
+		"$.ports[*].hosts",
+		"$.volumes.*.mounts",
+		"$.owner.Teams",
+	}, collections.Arrays)
+	assert.Equal(t, []Path{"$.updatedAt"}, ignored)
+}
+
+func TestCollectionsFor_NumericMapKeys_DriveCreatePatch(t *testing.T) {
+	collections, ignored, err := CollectionsFor[taggedService]()
+	require.NoError(t, err)
+
+	a := `{"volumes":{"0":{"mounts":["/a", "/b"]}}}`
+	b := `{"volumes":{"0":{"mounts":["/b", "/a"]}}}`
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, ignored, PatchStrategyExactMatch)
+	require.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/volumes/0/mounts/0", "/b"),
+		NewPatch("replace", "/volumes/0/mounts/1", "/a"),
+	}, patch)
+}
+
+func TestCollectionsFor_SliceOfStructs(t *testing.T) {
+	collections, ignored, err := CollectionsFor[[]taggedService]()
+	require.NoError(t, err)
+	assert.Equal(t, EntitySets{"$[*].ports": "name"}, collections.EntitySets)
+	assert.Equal(t, []Path{"$[*].updatedAt"}, ignored)
+}
+
+func TestCollectionsFor_InvalidTag_ReturnsError(t *testing.T) {
+	_, _, err := CollectionsFor[struct {
+		Items []string `json:"items" jsonpatch:"entityset"`
+	}]()
+	assert.Error(t, err)
+
+	_, _, err = CollectionsFor[struct {
+		Items []string `json:"items" jsonpatch:"list"`
+	}]()
+	assert.Error(t, err)
+
+	_, _, err = CollectionsFor[struct {
+		Items []string `json:"items" jsonpatch:"array,key=id"`
+	}]()
+	assert.Error(t, err)
+}
+
+func TestCollectionsFor_GoFieldNameKey_UsesJsonName(t *testing.T) {
+	collections, _, err := CollectionsFor[struct {
+		Ports []taggedPort  `json:"ports" jsonpatch:"entityset,key=Name"`
+		Steps []*taggedStep `json:"steps" jsonpatch:"keyedarray,key=ID"`
+	}]()
+	require.NoError(t, err)
+	assert.Equal(t, EntitySets{"$.ports": "name"}, collections.EntitySets)
+	assert.Equal(t, EntitySets{"$.steps": "id"}, collections.KeyedArrays)
+
+	_, _, err = CollectionsFor[struct {
+		Ports []taggedPort `json:"ports" jsonpatch:"entityset,key=Internal"`
+	}]()
+	assert.Error(t, err)
+
+	_, _, err = CollectionsFor[struct {
+		Ports []taggedPort `json:"ports" jsonpatch:"entityset,key=id"`
+	}]()
+	assert.Error(t, err)
+}
+
+func TestCollectionsFor_DrivesCreatePatch(t *testing.T) {
+	collections, ignored, err := CollectionsFor[taggedService]()
+	require.NoError(t, err)
+
+	a := `{"image":"app:1", "updatedAt":"2024-01-01T00:00:00Z", "tags":["a", "b"],
+		"ports":[{"name":"http", "port":80, "hosts":["x", "y"]}, {"name":"grpc", "port":81}],
+		"volumes":{"data":{"mounts":["/a", "/b"]}}}`
+	b := `{"image":"app:1", "updatedAt":"2024-02-01T00:00:00Z", "tags":["b", "a"],
+		"ports":[{"name":"grpc", "port":81}, {"name":"http", "port":8080, "hosts":["x", "y"]}],
+		"volumes":{"data":{"mounts":["/b", "/a"]}}}`
+	patch, err := CreatePatch([]byte(a), []byte(b), collections, ignored, PatchStrategyExactMatch)
+	require.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("replace", "/ports/0/port", float64(8080)),
+		NewPatch("replace", "/volumes/data/mounts/0", "/b"),
+		NewPatch("replace", "/volumes/data/mounts/1", "/a"),
+	}, patch)
+}
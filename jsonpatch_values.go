@@ -0,0 +1,478 @@
+package jsonpatch
+
+import (
+	"cmp"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CreatePatchFromValues creates a patch like CreatePatch, but from Go values
+// instead of json encoded documents. The values are walked directly, as
+// json.Marshal would encode them, without encoding and decoding them again.
+// Values already in their decoded form, such as map[string]any, are walked
+// the same way.
+//
+// An error will be returned if a value cannot be encoded as json, or if the
+// patch exceeds the limits set in options.
+func CreatePatchFromValues(a, b any, collections Collections, ignoredFields []Path, strategy PatchStrategy, options ...PatchOption) ([]JsonPatchOperation, error) {
+	aDecoded, err := decodeValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bDecoded, err := decodeValue(b)
+	if err != nil {
+		return nil, err
+	}
+	return createPatch(aDecoded, bDecoded, collections, ignoredFields, strategy, options)
+}
+
+// Diff creates the patch from 'a' to 'b', two values of the same type, as
+// CreatePatchFromValues does. CollectionsFor can derive the collections and
+// ignored fields from the struct tags of T.
+func Diff[T any](a, b T, collections Collections, ignoredFields []Path, strategy PatchStrategy, options ...PatchOption) ([]JsonPatchOperation, error) {
+	return CreatePatchFromValues(a, b, collections, ignoredFields, strategy, options...)
+}
+
+// decodeValue returns what json.Unmarshal would produce from the encoding of
+// v by json.Marshal. Only values implementing json.Marshaler or
+// encoding.TextMarshaler are actually encoded and decoded.
+//
+// This mirrors the encoding rules of encoding/json as of Go 1.24, the version
+// go.mod requires for the ",omitzero" option, and has to follow them when
+// they change. TestDecodeValue_RandomValues_MatchJsonRoundTrip compares the
+// two, so that drift shows up as a failing test rather than a wrong patch.
+func decodeValue(v any) (any, error) {
+	d := valueDecoder{}
+	return d.decode(reflect.ValueOf(v))
+}
+
+var (
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	isZeroerType      = reflect.TypeFor[interface{ IsZero() bool }]()
+	numberType        = reflect.TypeFor[json.Number]()
+)
+
+// startDetectingCyclesAfter is the nesting depth from which pointers, maps
+// and slices are checked for cycles, as json.Marshal does.
+const startDetectingCyclesAfter = 1000
+
+type valueDecoder struct {
+	depth int
+	seen  map[[2]uintptr]bool
+}
+
+func (d *valueDecoder) decode(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	t := v.Type()
+	if v.CanAddr() && implementsMarshaler(reflect.PointerTo(t)) {
+		return d.marshal(v.Addr())
+	}
+	if implementsMarshaler(t) && t.Kind() != reflect.Interface {
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, nil
+		}
+		return d.marshal(v)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return decodeFloat(v)
+	case reflect.String:
+		if t == numberType {
+			return d.marshal(v)
+		}
+		s := v.String()
+		if !utf8.ValidString(s) {
+			// json.Marshal replaces each invalid byte with U+FFFD.
+			s = string([]rune(s))
+		}
+		return s, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return d.decode(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return d.descend(v, 0, func() (any, error) { return d.decode(v.Elem()) })
+	case reflect.Map:
+		return d.decodeMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if elem := reflect.PointerTo(t.Elem()); t.Elem().Kind() == reflect.Uint8 && !implementsMarshaler(elem) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		return d.descend(v, v.Len(), func() (any, error) { return d.decodeArray(v) })
+	case reflect.Array:
+		return d.decodeArray(v)
+	case reflect.Struct:
+		return d.decodeStruct(v)
+	}
+	return nil, &json.UnsupportedTypeError{Type: t}
+}
+
+func implementsMarshaler(t reflect.Type) bool {
+	return t.Implements(marshalerType) || t.Implements(textMarshalerType)
+}
+
+// marshal decodes v through its json encoding.
+func (d *valueDecoder) marshal(v reflect.Value) (any, error) {
+	jsonBytes, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var result any
+	if err := json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeFloat returns the float64 json.Unmarshal would read back, which for
+// a float32 is the shortest decimal that identifies it.
+func decodeFloat(v reflect.Value) (any, error) {
+	f := v.Float()
+	bits := v.Type().Bits()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+	if bits == 32 {
+		return strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+	}
+	return f, nil
+}
+
+// descend decodes the pointer, map or slice v, failing when v is part of a
+// cycle.
+func (d *valueDecoder) descend(v reflect.Value, length int, decode func() (any, error)) (any, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > startDetectingCyclesAfter {
+		key := [2]uintptr{v.Pointer(), uintptr(length)}
+		if d.seen[key] {
+			return nil, &json.UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}
+		}
+		if d.seen == nil {
+			d.seen = map[[2]uintptr]bool{}
+		}
+		d.seen[key] = true
+		defer delete(d.seen, key)
+	}
+	return decode()
+}
+
+func (d *valueDecoder) decodeArray(v reflect.Value) (any, error) {
+	result := make([]any, v.Len())
+	for i := range result {
+		element, err := d.decode(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		result[i] = element
+	}
+	return result, nil
+}
+
+func (d *valueDecoder) decodeMap(v reflect.Value) (any, error) {
+	t := v.Type()
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !t.Key().Implements(textMarshalerType) {
+			return nil, &json.UnsupportedTypeError{Type: t}
+		}
+	}
+	if v.IsNil() {
+		return nil, nil
+	}
+	return d.descend(v, 0, func() (any, error) {
+		result := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			key, err := mapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.decode(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		}
+		return result, nil
+	})
+}
+
+// mapKey returns the member name json.Marshal uses for the map key k.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	default:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+}
+
+func (d *valueDecoder) decodeStruct(v reflect.Value) (any, error) {
+	fields := structFields(v.Type())
+	result := make(map[string]any, len(fields))
+FieldLoop:
+	for _, f := range fields {
+		fv := v
+		for _, i := range f.index {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue FieldLoop
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(i)
+		}
+		if f.omitEmpty && isEmptyValue(fv) || f.omitZero && isZeroValue(fv) {
+			continue
+		}
+		var value any
+		var err error
+		if f.quoted {
+			value, err = d.decodeQuoted(fv)
+		} else {
+			value, err = d.decode(fv)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[f.name] = value
+	}
+	return result, nil
+}
+
+// decodeQuoted decodes a field with the ",string" option, whose value is
+// encoded as a json string holding its json encoding.
+func (d *valueDecoder) decodeQuoted(v reflect.Value) (any, error) {
+	if implementsMarshaler(v.Type()) || v.CanAddr() && implementsMarshaler(reflect.PointerTo(v.Type())) {
+		return d.decode(v)
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	jsonBytes, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(jsonBytes), nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// isZeroValue reports whether a field with the ",omitzero" option is left
+// out, using the IsZero method of its type if it has one.
+func isZeroValue(v reflect.Value) bool {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return v.IsNil() || v.Elem().Kind() == reflect.Pointer && v.Elem().IsNil() || v.Interface().(interface{ IsZero() bool }).IsZero()
+	case t.Kind() == reflect.Pointer && t.Implements(isZeroerType):
+		return v.IsNil() || v.Interface().(interface{ IsZero() bool }).IsZero()
+	case t.Implements(isZeroerType):
+		return v.Interface().(interface{ IsZero() bool }).IsZero()
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		if !v.CanAddr() {
+			boxed := reflect.New(t).Elem()
+			boxed.Set(v)
+			v = boxed
+		}
+		return v.Addr().Interface().(interface{ IsZero() bool }).IsZero()
+	}
+	return v.IsZero()
+}
+
+// structField is a member json.Marshal encodes for a struct type.
+type structField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+// structFields returns the members of the struct type t, resolving the
+// fields promoted from embedded structs by the rules of encoding/json.
+func structFields(t reflect.Type) []structField {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField)
+	}
+	fields, _ := structFieldsCache.LoadOrStore(t, typeFields(t))
+	return fields.([]structField)
+}
+
+func typeFields(t reflect.Type) []structField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	current := []embedded{}
+	next := []embedded{{typ: t}}
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	fields := []structField{}
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := range f.typ.NumField() {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(f.index), i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					field := structField{
+						name:      name,
+						index:     index,
+						tagged:    name != "",
+						omitEmpty: hasTagOption(options, "omitempty"),
+						omitZero:  hasTagOption(options, "omitzero"),
+					}
+					if field.name == "" {
+						field.name = sf.Name
+					}
+					if hasTagOption(options, "string") {
+						switch ft.Kind() {
+						case reflect.Bool,
+							reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64, reflect.String:
+							field.quoted = true
+						}
+					}
+					fields = append(fields, field)
+					if count[f.typ] > 1 {
+						// A struct embedded twice at the same depth conflicts
+						// with itself, which the duplicate brings out below.
+						fields = append(fields, field)
+					}
+					continue
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, embedded{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b structField) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.index), len(b.index)); c != 0 {
+			return c
+		}
+		if a.tagged != b.tagged {
+			if a.tagged {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+	// Of the fields sharing a name, the shallowest wins, then the tagged one.
+	// Fields that still conflict are all left out.
+	result := []structField{}
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if j-i == 1 || len(fields[i].index) != len(fields[i+1].index) || fields[i].tagged != fields[i+1].tagged {
+			result = append(result, fields[i])
+		}
+		i = j
+	}
+	return result
+}
+
+func hasTagOption(options, option string) bool {
+	for options != "" {
+		var name string
+		name, options, _ = strings.Cut(options, ",")
+		if name == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roundTrip(t *testing.T, v any) any {
+	t.Helper()
+	jsonBytes, err := json.Marshal(v)
+	require.NoError(t, err)
+	var result any
+	require.NoError(t, json.Unmarshal(jsonBytes, &result))
+	return result
+}
+
+type valuesInner struct {
+	Name  string `json:"name"`
+	Count int    `json:"count,omitempty"`
+}
+
+type valuesShadowed struct {
+	Name  string `json:"name"`
+	Other string
+}
+
+type valuesConflictA struct{ Conflict string }
+type valuesConflictB struct{ Conflict string }
+
+type valuesEmbedded struct {
+	valuesInner
+	*valuesShadowed
+	valuesConflictA
+	valuesConflictB
+	Name string `json:"id"`
+}
+
+type valuesPointerMarshaler struct{ n int }
+
+func (v *valuesPointerMarshaler) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{"n": v.n})
+}
+
+type valuesZeroer struct{ Set bool }
+
+func (v valuesZeroer) IsZero() bool { return !v.Set }
+
+type valuesOptions struct {
+	Skipped   string            `json:"-"`
+	Dash      string            `json:"-,"`
+	Empty     []int             `json:"empty,omitempty"`
+	Zero      valuesZeroer      `json:"zero,omitzero"`
+	NotZero   valuesZeroer      `json:"notZero,omitzero"`
+	Quoted    int               `json:"quoted,string"`
+	QuotedStr string            `json:"quotedStr,string"`
+	QuotedPtr *float64          `json:"quotedPtr,string"`
+	Bytes     []byte            `json:"bytes"`
+	Floats    []float32         `json:"floats"`
+	Keys      map[int]string    `json:"keys"`
+	IPs       map[string]net.IP `json:"ips"`
+	Time      time.Time         `json:"time"`
+	Raw       json.RawMessage   `json:"raw"`
+	Number    json.Number       `json:"number"`
+	Marshaler valuesPointerMarshaler
+	Array     [2]*valuesInner
+	Invalid   string
+	unexposed string
+}
+
+func TestDecodeValue_MatchesJsonRoundTrip(t *testing.T) {
+	half := 0.5
+	values := []any{
+		nil,
+		true,
+		"<a&b>",
+		uint8(7),
+		int64(math.MaxInt64),
+		float32(0.1),
+		[]any{1, "2", nil},
+		map[string]any{"a": []int{1, 2}, "b": map[string]any{}},
+		[]string(nil),
+		map[string]int(nil),
+		&valuesInner{Name: "x"},
+		valuesEmbedded{valuesInner: valuesInner{Name: "inner", Count: 1}, valuesShadowed: &valuesShadowed{Name: "s", Other: "o"}, Name: "outer"},
+		valuesEmbedded{},
+		valuesOptions{
+			Dash:      "dash",
+			NotZero:   valuesZeroer{Set: true},
+			Quoted:    42,
+			QuotedStr: `"<x>"`,
+			QuotedPtr: &half,
+			Bytes:     []byte("bytes"),
+			Floats:    []float32{0.1, 1e20},
+			Keys:      map[int]string{-1: "a", 2: "b"},
+			IPs:       map[string]net.IP{"local": net.IPv4(127, 0, 0, 1)},
+			Time:      time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+			Raw:       json.RawMessage(`{"raw": [1]}`),
+			Number:    "1.5e3",
+			Marshaler: valuesPointerMarshaler{n: 3},
+			Array:     [2]*valuesInner{{Name: "a"}},
+			Invalid:   "a\xffb\xfe",
+			unexposed: "hidden",
+		},
+		[]valuesPointerMarshaler{{n: 1}},
+		map[string]valuesPointerMarshaler{"a": {n: 1}},
+	}
+	for _, v := range values {
+		decoded, err := decodeValue(v)
+		require.NoError(t, err)
+		assert.Equal(t, roundTrip(t, v), decoded, "%#v", v)
+	}
+}
+
+type valuesRandom struct {
+	Bool    bool
+	Int     int16            `json:"int,omitempty"`
+	Uint    uint32           `json:",string"`
+	Float   float32          `json:"float"`
+	String  string           `json:"string,omitempty"`
+	Strings []string         `json:"strings"`
+	Map     map[string]*int8 `json:"map"`
+	Keys    map[uint8]bool   `json:"keys"`
+	Inner   *valuesInner     `json:"inner,omitzero"`
+	Items   []valuesInner    `json:"items"`
+	Bytes   []byte           `json:"bytes,omitempty"`
+}
+
+func TestDecodeValue_RandomValues_MatchJsonRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for range 1000 {
+		value, ok := quick.Value(reflect.TypeFor[valuesRandom](), r)
+		require.True(t, ok)
+		decoded, err := decodeValue(value.Interface())
+		require.NoError(t, err)
+		require.Equal(t, roundTrip(t, value.Interface()), decoded)
+	}
+}
+
+func TestDecodeValue_Unencodable_ReturnsError(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	cycle := &node{}
+	cycle.Next = cycle
+
+	var unsupportedType *json.UnsupportedTypeError
+	var unsupportedValue *json.UnsupportedValueError
+	_, err := decodeValue(map[string]any{"c": make(chan int)})
+	assert.True(t, errors.As(err, &unsupportedType))
+	_, err = decodeValue(map[[2]int]string{})
+	assert.True(t, errors.As(err, &unsupportedType))
+	_, err = decodeValue([]float64{math.Inf(1)})
+	assert.True(t, errors.As(err, &unsupportedValue))
+	_, err = decodeValue(cycle)
+	assert.True(t, errors.As(err, &unsupportedValue))
+}
+
+func TestCreatePatchFromValues_MatchesCreatePatch(t *testing.T) {
+	collections, ignored, err := CollectionsFor[taggedService]()
+	require.NoError(t, err)
+
+	a := taggedService{
+		Image: "app:1",
+		Tags:  []string{"a", "b"},
+		Ports: []taggedPort{{Name: "http", Port: 80, Hosts: []string{"x"}}, {Name: "grpc", Port: 81}},
+		Volumes: map[string]taggedVolume{
+			"data": {Mounts: []string{"/a", "/b"}},
+		},
+	}
+	b := a
+	b.Image = "app:2"
+	b.taggedMetadata = taggedMetadata{Labels: map[string]string{"team": "x"}, UpdatedAt: time.Now()}
+	b.Ports = []taggedPort{{Name: "grpc", Port: 81}, {Name: "http", Port: 8080, Hosts: []string{"x", "y"}}}
+	b.Steps = []taggedStep{{ID: "build"}}
+	b.Owner = &taggedOwner{Name: "me"}
+
+	aJson, err := json.Marshal(a)
+	require.NoError(t, err)
+	bJson, err := json.Marshal(b)
+	require.NoError(t, err)
+	expected, err := CreatePatch(aJson, bJson, collections, ignored, PatchStrategyExactMatch)
+	require.NoError(t, err)
+	require.NotEmpty(t, expected)
+
+	patch, err := Diff(a, b, collections, ignored, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, patch)
+
+	patch, err = CreatePatchFromValues(&a, roundTrip(t, b), collections, ignored, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, patch)
+}
+
+func TestCreatePatchFromValues_DecodedValues(t *testing.T) {
+	a := map[string]any{"name": "a", "replicas": 1, "ports": []any{80}}
+	b := map[string]any{"name": "a", "replicas": 2, "ports": []int{80, 443}}
+	patch, err := CreatePatchFromValues(a, b, Collections{}, nil, PatchStrategyExactMatch)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonPatchOperation{
+		NewPatch("add", "/ports/1", float64(443)),
+		NewPatch("replace", "/replicas", float64(2)),
+	}, patch)
+}
+
+func TestCreatePatchFromValues_Unencodable_ReturnsError(t *testing.T) {
+	_, err := CreatePatchFromValues(map[string]any{}, map[string]any{"f": func() {}}, Collections{}, nil, PatchStrategyExactMatch)
+	assert.Error(t, err)
+}
+
+func benchmarkServices() (taggedService, taggedService) {
+	a := taggedService{Image: "app:1", Volumes: map[string]taggedVolume{}}
+	for i := range 100 {
+		a.Ports = append(a.Ports, taggedPort{Name: string(rune('a'+i%26)) + string(rune('a'+i/26)), Port: i, Hosts: []string{"x", "y"}})
+		a.Volumes[string(rune('a'+i%26))] = taggedVolume{Mounts: []string{"/a", "/b"}}
+	}
+	b := a
+	b.Ports = append([]taggedPort{}, a.Ports...)
+	b.Ports[50].Port = 8080
+	return a, b
+}
+
+func BenchmarkDiff(b *testing.B) {
+	collections, ignored, _ := CollectionsFor[taggedService]()
+	x, y := benchmarkServices()
+	for i := 0; i < b.N; i++ {
+		_, _ = Diff(x, y, collections, ignored, PatchStrategyExactMatch)
+	}
+}
+
+func BenchmarkDiffMarshalled(b *testing.B) {
+	collections, ignored, _ := CollectionsFor[taggedService]()
+	x, y := benchmarkServices()
+	for i := 0; i < b.N; i++ {
+		xJson, _ := json.Marshal(x)
+		yJson, _ := json.Marshal(y)
+		_, _ = CreatePatch(xJson, yJson, collections, ignored, PatchStrategyExactMatch)
+	}
+}